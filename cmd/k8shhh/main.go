@@ -1,11 +1,15 @@
 package main
 
 import (
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	. "github.com/jwangsadinata/k8shhh"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -19,15 +23,86 @@ const (
 var (
 	app = kingpin.New("k8shhh", "k8shhh: Quickly encode your configuration into K8s secrets.")
 
-	enc           = app.Command("encode", "encode your configuration as k8s secrets")
-	encSecretName = enc.Flag("name", "the name of the generated secret").Short('n').String()
-	encInput      = enc.Flag("input", "the name of the input file to encode (if input is not provided via STDIN)").Short('i').String()
-	encOutput     = enc.Flag("output", "the name of the file to write the output to (outputs to STDOUT by default). file extension will be automatically generated based on the format.").Short('o').String()
-	encFormat     = enc.Flag("format", "format of the generated secret (json or yaml, defaults to yaml)").Default("yaml").Short('f').String()
+	enc            = app.Command("encode", "encode your configuration as k8s secrets")
+	encSecretName  = enc.Flag("name", "the name of the generated secret").Short('n').String()
+	encOutput      = enc.Flag("output", "the name of the file to write the output to (outputs to STDOUT by default). file extension will be automatically generated based on the format.").Short('o').String()
+	encFormat      = enc.Flag("format", "format of the generated secret (json, yaml or helm, defaults to yaml). helm generates a helm template reading the values from .Values.secrets, whose values are written to --helm-values.").Default("yaml").Short('f').String()
+	encNamespace   = enc.Flag("namespace", "the namespace of the generated secret").String()
+	encLabels      = enc.Flag("label", "a label of the generated secret in the form key=value (can be repeated)").Short('l').StringMap()
+	encAnnotations = enc.Flag("annotation", "an annotation of the generated secret in the form key=value (can be repeated)").Short('a').StringMap()
+	encDataMode    = enc.Flag("data-mode", "where the values of the generated secret are written to (data, stringData or hybrid, defaults to data). hybrid writes printable values to stringData and binary ones to data.").Default(string(DataModeData)).Enum(string(DataModeData), string(DataModeStringData), string(DataModeHybrid))
+	encSealCert    = enc.Flag("seal-cert", "the certificate or public key of the sealed secrets controller (e.g. from `kubeseal --fetch-cert`), to generate a SealedSecret instead of a secret").ExistingFile()
+	encSOPSAge     = enc.Flag("sops-age", "an age recipient of the form age1..., to encrypt the values of the generated secret to with SOPS (can be repeated)").Strings()
+	encImmutable   = enc.Flag("immutable", "mark the generated secret as immutable").Bool()
+	encHashSuffix  = enc.Flag("hash-suffix", "append a hash of the data and type to the name of the generated secret, in the same way as the secretGenerator of kustomize, and mark it as immutable").Bool()
+	encHelmValues  = enc.Flag("helm-values", "the file to write the values.yaml fragment of the helm template to, which is required by --format helm").String()
+	encSOPSPGP     = enc.Flag("sops-pgp", "an OpenPGP keyring holding the public keys to encrypt the values of the generated secret to with SOPS").ExistingFile()
 
-	dec       = app.Command("decode", "decode your k8s secrets into a readable format")
-	decInput  = dec.Flag("input", "the name of the input file to decode (if input is not provided via STDIN)").Short('i').String()
-	decOutput = dec.Flag("output", "the name of the file to write the output to (outputs to STDOUT by default)").Short('o').String()
+	encEnv   = enc.Command("env", "encode a dotenv configuration (default)").Default()
+	encInput = encEnv.Flag("input", "the name of the input file to encode (if input is not provided via STDIN). when repeated, a secret named after each file is generated.").Short('i').Strings()
+	encType  = encEnv.Flag("type", "the type of the generated secret (e.g. Opaque, kubernetes.io/tls or kubernetes.io/basic-auth, defaults to Opaque)").Default("Opaque").Short('t').String()
+
+	encFromFiles = encEnv.Flag("from-file", "a file to add to the secret in the form [key=]path, keyed by the file name by default (can be repeated). STDIN is not read unless --input is given.").Strings()
+	encFromDirs  = encEnv.Flag("from-dir", "a directory whose files are added to the secret, keyed by the file names (can be repeated). STDIN is not read unless --input is given.").Strings()
+
+	encAgeIdentities = encEnv.Flag("age-identity", "an age identity file, to decrypt inputs encrypted with age or SOPS (can be repeated)").ExistingFiles()
+	encPGPKey        = encEnv.Flag("pgp-key", "an OpenPGP keyring holding the private key, to decrypt inputs encrypted with OpenPGP or SOPS").ExistingFile()
+
+	encTLS     = enc.Command("tls", "encode a certificate and its private key as a kubernetes.io/tls secret")
+	encTLSCert = encTLS.Flag("cert", "the PEM encoded certificate chain, starting with the leaf certificate").Required().ExistingFile()
+	encTLSKey  = encTLS.Flag("key", "the PEM encoded private key of the certificate").Required().ExistingFile()
+	encTLSCA   = encTLS.Flag("ca", "the PEM encoded certificate of the issuing CA").ExistingFile()
+
+	encDocker         = enc.Command("docker-registry", "encode docker registry credentials as a kubernetes.io/dockerconfigjson secret")
+	encDockerServer   = encDocker.Flag("server", "the server of the docker registry").Default(DefaultDockerServer).String()
+	encDockerUsername = encDocker.Flag("username", "the username of the docker registry").String()
+	encDockerPassword = encDocker.Flag("password", "the password of the docker registry").String()
+	encDockerEmail    = encDocker.Flag("email", "the email of the docker registry").String()
+	encDockerConfig   = encDocker.Flag("config", "an existing docker config file (e.g. ~/.docker/config.json) to use instead of the credentials").ExistingFile()
+
+	dec              = app.Command("decode", "decode your k8s secrets into a readable format")
+	decInput         = dec.Flag("input", "the name of the input file to decode (if input is not provided via STDIN)").Short('i').String()
+	decInputFormat   = dec.Flag("input-format", "format of the input (auto, json, yaml or dotenv, defaults to auto). auto detects the format from the content of the input.").Default("auto").Enum("auto", string(InputFormatJSON), string(InputFormatYAML), string(InputFormatDotenv))
+	decOutput        = dec.Flag("output", "the name of the file to write the output to (outputs to STDOUT by default)").Short('o').String()
+	decFormat        = dec.Flag("format", "format of the decoded output (env, json, yaml, shell, docker or systemd, defaults to env). docker is the format of `docker run --env-file`, and systemd the one of EnvironmentFile.").Default("env").Short('f').Enum("env", "json", "yaml", "shell", "docker", "systemd")
+	decToDir         = dec.Flag("to-dir", "the directory to write each key of the secret to, as its own file").String()
+	decSplit         = dec.Flag("split-dir", "the directory to write each secret of the input to, as its own file named after the secret").String()
+	decForce         = dec.Flag("force", "overwrite existing files when writing to a directory").Bool()
+	decRedact        = dec.Flag("redact", "show the kind, the length and a prefix of the SHA-256 hash of each value instead of the value itself").Bool()
+	decSealKey       = dec.Flag("seal-key", "the private key of the sealed secrets controller, to unseal the SealedSecrets of the input").ExistingFile()
+	decAgeRecipients = dec.Flag("age-recipient", "an age recipient of the form age1..., to encrypt the output to (can be repeated)").Strings()
+	decPGPRecipient  = dec.Flag("pgp-recipient", "an OpenPGP keyring holding the public keys to encrypt the output to").ExistingFile()
+	decAgeIdentities = dec.Flag("age-identity", "an age identity file, to decrypt inputs encrypted with age or SOPS (can be repeated)").ExistingFiles()
+	decPGPKey        = dec.Flag("pgp-key", "an OpenPGP keyring holding the private key, to decrypt inputs encrypted with OpenPGP or SOPS").ExistingFile()
+
+	diff              = app.Command("diff", "compare the keys of two secrets, or of a secret and a dotenv configuration. exits with 1 when they differ.")
+	diffOld           = diff.Arg("old", "the old secret manifest or dotenv file").Required().ExistingFile()
+	diffNew           = diff.Arg("new", "the new secret manifest or dotenv file").Required().ExistingFile()
+	diffInputFormat   = diff.Flag("input-format", "format of both inputs (auto, json, yaml or dotenv, defaults to auto). auto detects the format from the content of each input.").Default("auto").Enum("auto", string(InputFormatJSON), string(InputFormatYAML), string(InputFormatDotenv))
+	diffReveal        = diff.Flag("reveal", "show the values of the changed keys, instead of a prefix of their SHA-256 hash and their length").Bool()
+	diffSealKey       = diff.Flag("seal-key", "the private key of the sealed secrets controller, to unseal SealedSecrets").ExistingFile()
+	diffAgeIdentities = diff.Flag("age-identity", "an age identity file, to decrypt inputs encrypted with age or SOPS (can be repeated)").ExistingFiles()
+	diffPGPKey        = diff.Flag("pgp-key", "an OpenPGP keyring holding the private key, to decrypt inputs encrypted with OpenPGP or SOPS").ExistingFile()
+
+	kus                 = app.Command("kustomize", "render the secrets of a kustomization, or generate a secretGenerator entry from a dotenv file")
+	kusBuild            = kus.Command("build", "print the secrets generated by the secretGenerator of a kustomization, as `kustomize build` does (default)").Default()
+	kusBuildDir         = kusBuild.Arg("dir", "the directory holding the kustomization.yaml (defaults to the current directory)").Default(".").ExistingDir()
+	kusBuildFormat      = kusBuild.Flag("format", "format of the generated secrets (json or yaml, defaults to yaml)").Default("yaml").Short('f').Enum("json", "yaml")
+	kusBuildDataMode    = kusBuild.Flag("data-mode", "where the values of the generated secrets are written to (data, stringData or hybrid, defaults to data)").Default(string(DataModeData)).Enum(string(DataModeData), string(DataModeStringData), string(DataModeHybrid))
+	kusBuildAgeIdentity = kusBuild.Flag("age-identity", "an age identity file, to decrypt env files encrypted with age or SOPS (can be repeated)").ExistingFiles()
+	kusBuildPGPKey      = kusBuild.Flag("pgp-key", "an OpenPGP keyring holding the private key, to decrypt env files encrypted with OpenPGP or SOPS").ExistingFile()
+
+	kusGen            = kus.Command("generator", "print a secretGenerator entry of a kustomization.yaml reading the given dotenv file")
+	kusGenInput       = kusGen.Arg("env", "the dotenv file of the secret").Required().ExistingFile()
+	kusGenName        = kusGen.Flag("name", "the name of the generated secret (defaults to the name of the file without extension)").Short('n').String()
+	kusGenNamespace   = kusGen.Flag("namespace", "the namespace of the generated secret").String()
+	kusGenType        = kusGen.Flag("type", "the type of the generated secret").Short('t').String()
+	kusGenBehavior    = kusGen.Flag("behavior", "how the generated secret relates to an existing secret of the same name (create, merge or replace)").Enum(string(GeneratorBehaviorCreate), string(GeneratorBehaviorMerge), string(GeneratorBehaviorReplace))
+	kusGenLabels      = kusGen.Flag("label", "a label of the generated secret in the form key=value (can be repeated)").Short('l').StringMap()
+	kusGenAnnotations = kusGen.Flag("annotation", "an annotation of the generated secret in the form key=value (can be repeated)").Short('a').StringMap()
+	kusGenImmutable   = kusGen.Flag("immutable", "mark the generated secret as immutable").Bool()
+	kusGenNoHash      = kusGen.Flag("disable-name-suffix-hash", "do not append the hash of the content to the name of the generated secret").Bool()
+	kusGenLiterals    = kusGen.Flag("literals", "write the values of the file to the entry as literals, instead of referring to the file").Bool()
 
 	version = app.Command("version", "print the current version of k8shhh.")
 )
@@ -42,40 +117,119 @@ func run() int {
 	}
 
 	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
-	case enc.FullCommand():
-		if isInteractive() && *encInput == "" {
+	case encEnv.FullCommand():
+		fromFiles := len(*encFromFiles) > 0 || len(*encFromDirs) > 0
+		if isInteractive() && len(*encInput) == 0 && !fromFiles {
 			kingpin.CommandLine.UsageForContext(ctx)
 			fmt.Fprintln(os.Stderr, "expecting input on stdin")
 			return 1
 		}
 
-		if *encFormat != "json" && *encFormat != "yaml" {
-			kingpin.CommandLine.UsageForContext(ctx)
-			fmt.Fprintln(os.Stderr, "format must be either yaml or json")
+		if !checkEncodeFormat(ctx) {
 			return 1
 		}
 
-		input, err := selectInput(*encInput)
+		if len(*encInput) > 1 {
+			if *encSecretName != "" || fromFiles {
+				kingpin.CommandLine.UsageForContext(ctx)
+				fmt.Fprintln(os.Stderr, "--name, --from-file and --from-dir cannot be given with several inputs")
+				return 1
+			}
+			return encodeInputs(*encInput)
+		}
+
+		data, err := readFileSources(*encFromFiles, *encFromDirs)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "reading input file: %s", err)
 			return 1
 		}
-		defer input.Close()
 
-		encoder := selectEncoder(*encFormat)
-		secretName := initializeSecretName(*encSecretName, *encOutput)
-		output, err := Encode(input, encoder, secretName)
+		encoder, err := selectEncoder(*encFormat)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
 			return 1
 		}
+		secret := initializeSecret(SecretType(*encType))
+		secret.Data = data
+
+		var name string
+		if len(*encInput) == 1 {
+			name = (*encInput)[0]
+		}
+		var input io.Reader = strings.NewReader("")
+		if name != "" || !fromFiles {
+			input, err = readEnvInput(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "reading input file: %s", err)
+				return 1
+			}
+		}
 
-		msg, err := processEncodeOutput(output, *encOutput, *encFormat)
+		secret, err = ParseSecret(input, secret)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "writing to output file: %s", err)
+			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
 			return 1
 		}
-		fmt.Print(msg)
+
+		return printEncodeSecret(encoder, secret)
+	case encTLS.FullCommand():
+		if !checkEncodeFormat(ctx) {
+			return 1
+		}
+
+		files, err := readFiles(*encTLSCert, *encTLSKey, *encTLSCA)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading input file: %s", err)
+			return 1
+		}
+
+		data, warnings, err := TLSData(files[0], files[1], files[2], time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+			return 1
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+
+		encoder, err := selectEncoder(*encFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+			return 1
+		}
+		secret := initializeSecret(SecretTypeTLS)
+		secret.Data = data
+		return printEncodeSecret(encoder, secret)
+	case encDocker.FullCommand():
+		if !checkEncodeFormat(ctx) {
+			return 1
+		}
+
+		files, err := readFiles(*encDockerConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading input file: %s", err)
+			return 1
+		}
+
+		var data map[string]string
+		if *encDockerConfig != "" {
+			data, err = DockerConfigData(files[0])
+		} else {
+			data, err = DockerRegistryData(*encDockerServer, *encDockerUsername, *encDockerPassword, *encDockerEmail)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+			return 1
+		}
+
+		encoder, err := selectEncoder(*encFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+			return 1
+		}
+		secret := initializeSecret(SecretTypeDockerConfigJSON)
+		secret.Data = data
+		return printEncodeSecret(encoder, secret)
 	case dec.FullCommand():
 		if isInteractive() && *decInput == "" {
 			kingpin.CommandLine.UsageForContext(ctx)
@@ -83,25 +237,100 @@ func run() int {
 			return 1
 		}
 
-		input, err := selectInput(*decInput)
+		input, err := readDecodeInput(*decInput)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "reading input file: %s", err)
 			return 1
 		}
 
-		decoder := selectDecoder(*decInput)
-		output, err := Decode(input, decoder)
+		if countSet(*decOutput, *decToDir, *decSplit) > 1 {
+			kingpin.CommandLine.UsageForContext(ctx)
+			fmt.Fprintln(os.Stderr, "only one of --output, --to-dir and --split-dir can be given")
+			return 1
+		}
+
+		if *decRedact && countSet(*decToDir, *decSplit) > 0 {
+			kingpin.CommandLine.UsageForContext(ctx)
+			fmt.Fprintln(os.Stderr, "--redact cannot be given with --to-dir or --split-dir")
+			return 1
+		}
+
+		if *decFormat != "env" && (*decRedact || countSet(*decToDir, *decSplit) > 0) {
+			kingpin.CommandLine.UsageForContext(ctx)
+			fmt.Fprintln(os.Stderr, "--format cannot be given with --redact, --to-dir or --split-dir")
+			return 1
+		}
+
+		key, err := readUnsealingKey(*decSealKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading sealing key: %s", err)
+			return 1
+		}
+
+		decoder, err := selectDecoder(*decInputFormat)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "error in decoding: %v\n", err)
 			return 1
 		}
 
+		secrets, warnings, err := DecodeSealedSecrets(input, decoder, key)
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in decoding: %v\n", err)
+			return 1
+		}
+
+		encrypt, err := selectEncrypter()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading recipients: %s\n", err)
+			return 1
+		}
+
+		if *decToDir != "" || *decSplit != "" {
+			var files []string
+			if *decToDir != "" {
+				files, err = SecretsToDir(secrets, *decToDir, *decForce, encrypt)
+			} else {
+				files, err = SecretsToFiles(secrets, *decSplit, *decForce, encrypt)
+			}
+			for _, f := range files {
+				fmt.Printf("file \"%s\" created\n", f)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error in decoding: %v\n", err)
+				return 1
+			}
+			return 0
+		}
+
+		var output []byte
+		if *decRedact {
+			output = FormatRedactedSecrets(secrets)
+		} else if output, err = selectFormatter(*decFormat)(secrets); err != nil {
+			fmt.Fprintf(os.Stderr, "error in decoding: %v\n", err)
+			return 1
+		}
+		if encrypt != nil {
+			if output, err = encrypt(output); err != nil {
+				fmt.Fprintf(os.Stderr, "error in encrypting: %v\n", err)
+				return 1
+			}
+		}
+
 		msg, err := processDecodeOutput(output, *decOutput)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "writing to output file: %s", err)
 			return 1
 		}
 		fmt.Print(msg)
+	case diff.FullCommand():
+		return diffInputs(*diffOld, *diffNew)
+	case kusBuild.FullCommand():
+		return kustomizeBuild(*kusBuildDir)
+	case kusGen.FullCommand():
+		return kustomizeGenerator(*kusGenInput)
 	case version.FullCommand():
 		fmt.Printf("k8shhh %s\n", VERSION)
 	}
@@ -116,29 +345,367 @@ func checkExtension(output string) bool {
 }
 
 // checkFormat checks whether the format passed is correct
-func checkFormat(format string) int {
-	return 0
+func checkFormat(format string) bool {
+	return format == "json" || format == "yaml" || format == "helm"
+}
+
+// checkEncodeFormat checks the --format and --helm-values flags shared by the
+// encode commands, and prints the usage along with the problem if they are
+// invalid
+func checkEncodeFormat(ctx *kingpin.ParseContext) bool {
+	var problem string
+	switch {
+	case !checkFormat(*encFormat):
+		problem = "format must be either yaml, json or helm"
+	case (*encFormat == "helm") != (*encHelmValues != ""):
+		problem = "--helm-values must be given with, and only with, --format helm"
+	default:
+		return true
+	}
+	kingpin.CommandLine.UsageForContext(ctx)
+	fmt.Fprintln(os.Stderr, problem)
+	return false
+}
+
+// countSet returns the number of the given flag values which are set
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
 }
 
-// initializeSecretName initializes the secret name
+// initializeSecretName initializes the secret name, which defaults to the
+// base name of the output file without its .json or .yaml extension
 func initializeSecretName(sn, output string) string {
 	if sn == "" {
 		if output == "" {
 			return "mysecret"
 		}
-		return trimExtension(*encOutput)
+		return trimExtension(filepath.Base(output))
 	}
 	return sn
 }
 
+// initializeSecret initializes a secret of the given type with the name and
+// metadata provided on the command line
+func initializeSecret(secretType SecretType) Secret {
+	return Secret{
+		Name:        initializeSecretName(*encSecretName, *encOutput),
+		Namespace:   *encNamespace,
+		Labels:      *encLabels,
+		Annotations: *encAnnotations,
+		Type:        secretType,
+		Immutable:   *encImmutable,
+		HashSuffix:  *encHashSuffix,
+	}
+}
+
+// encodeInputs encodes each of the given dotenv files into a secret named
+// after the file, and writes them all to the output. It returns the exit code.
+func encodeInputs(names []string) int {
+	secrets := make([]Secret, 0, len(names))
+	for _, name := range names {
+		input, err := readEnvInput(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading input file: %s", err)
+			return 1
+		}
+		secret := initializeSecret(SecretType(*encType))
+		secret.Name = secretNameFromFile(name)
+		secret, err = ParseSecret(input, secret)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding %s: %v\n", name, err)
+			return 1
+		}
+		secrets = append(secrets, secret)
+	}
+
+	encoder, err := selectListEncoder(*encFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+		return 1
+	}
+	output, err := encoder(secrets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+		return 1
+	}
+
+	return printEncodeOutput(output)
+}
+
+// diffInputs prints the keys added, removed and changed between the old and
+// the new input, and returns the exit code: 0 when they hold the same data,
+// 1 when they differ and 2 on errors, as diff does
+func diffInputs(oldName, newName string) int {
+	key, err := readUnsealingKey(*diffSealKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reading sealing key: %s\n", err)
+		return 2
+	}
+	oldData, err := readDiffInput(oldName, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in reading %s: %v\n", oldName, err)
+		return 2
+	}
+	newData, err := readDiffInput(newName, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in reading %s: %v\n", newName, err)
+		return 2
+	}
+
+	changes := DiffData(oldData, newData)
+	fmt.Print(string(FormatChanges(changes, *diffReveal)))
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// kustomizeBuild prints the secrets of the kustomization in the given
+// directory, and returns the exit code
+func kustomizeBuild(dir string) int {
+	keys, err := readDecryptionKeys(*kusBuildAgeIdentity, *kusBuildPGPKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reading input file: %s\n", err)
+		return 1
+	}
+	secrets, err := KustomizeSecrets(dir, keys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in kustomizing: %v\n", err)
+		return 1
+	}
+
+	encoder := YAMLListEncoder(DataMode(*kusBuildDataMode))
+	if *kusBuildFormat == "json" {
+		encoder = JSONListEncoder(DataMode(*kusBuildDataMode))
+	}
+	output, err := encoder(secrets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+		return 1
+	}
+	fmt.Print(string(output))
+	return 0
+}
+
+// kustomizeGenerator prints the secretGenerator entry of the named dotenv
+// file, and returns the exit code
+func kustomizeGenerator(name string) int {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reading input file: %s\n", err)
+		return 1
+	}
+
+	args := SecretGeneratorArgs{
+		Name:      *kusGenName,
+		Namespace: *kusGenNamespace,
+		Behavior:  GeneratorBehavior(*kusGenBehavior),
+		Type:      SecretType(*kusGenType),
+	}
+	if args.Name == "" {
+		args.Name = secretNameFromFile(name)
+	}
+	if len(*kusGenLabels) > 0 || len(*kusGenAnnotations) > 0 || *kusGenImmutable || *kusGenNoHash {
+		args.Options = &GeneratorOptions{
+			Labels:                *kusGenLabels,
+			Annotations:           *kusGenAnnotations,
+			DisableNameSuffixHash: *kusGenNoHash,
+			Immutable:             *kusGenImmutable,
+		}
+	}
+
+	secret, err := ParseSecret(strings.NewReader(string(b)), Secret{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+		return 1
+	}
+	if *kusGenLiterals {
+		if args.Literals, err = LiteralSources(secret.Data); err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+			return 1
+		}
+	} else {
+		args.Envs = []string{name}
+		keys, err := KustomizeEnvMismatches(b)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding: %v, use --literals to read it with k8shhh instead\n", err)
+			return 1
+		}
+		for _, k := range keys {
+			fmt.Fprintf(os.Stderr, "warning: value of key %q is read differently by kustomize, use --literals to keep it\n", k)
+		}
+	}
+
+	output, err := SecretGeneratorStanza(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+		return 1
+	}
+	fmt.Print(string(output))
+	return 0
+}
+
+// readDiffInput reads the data of the named input, which is either a secret
+// manifest holding a single secret or a dotenv configuration
+func readDiffInput(name string, key *rsa.PrivateKey) (map[string]string, error) {
+	input, err := readEncryptedInput(name, *diffAgeIdentities, *diffPGPKey)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := selectDecoder(*diffInputFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, warnings, err := DecodeSealedSecrets(input, decoder, key)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(secrets) != 1 {
+		return nil, fmt.Errorf("found %d secrets, but only a single secret can be compared", len(secrets))
+	}
+	return secrets[0].Data, nil
+}
+
+// readEnvInput reads the named dotenv input, or STDIN if there is none, and
+// decrypts it with the keys given on the command line if it is encrypted
+func readEnvInput(name string) (io.Reader, error) {
+	return readEncryptedInput(name, *encAgeIdentities, *encPGPKey)
+}
+
+// readDecodeInput reads the named input to decode, or STDIN if there is
+// none, and decrypts it with the keys given on the command line if it is
+// encrypted
+func readDecodeInput(name string) (io.Reader, error) {
+	return readEncryptedInput(name, *decAgeIdentities, *decPGPKey)
+}
+
+// readEncryptedInput reads the named input, or STDIN if there is none, and
+// decrypts it with the given age identity files and OpenPGP keyring if it is
+// encrypted
+func readEncryptedInput(name string, ageIdentities []string, pgpKey string) (io.Reader, error) {
+	input, err := selectInput(name)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	keys, err := readDecryptionKeys(ageIdentities, pgpKey)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptInput(input, keys)
+}
+
+// readDecryptionKeys reads the given age identity files and OpenPGP keyring
+func readDecryptionKeys(ageIdentities []string, pgpKey string) (DecryptionKeys, error) {
+	var keys DecryptionKeys
+	for _, f := range ageIdentities {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return DecryptionKeys{}, err
+		}
+		keys.AgeIdentities = append(append(keys.AgeIdentities, b...), '\n')
+	}
+	if pgpKey != "" {
+		b, err := ioutil.ReadFile(pgpKey)
+		if err != nil {
+			return DecryptionKeys{}, err
+		}
+		keys.PGPKeyring = b
+	}
+	return keys, nil
+}
+
+// selectEncrypter returns the encrypter of the decoded output based on the
+// recipients given on the command line, which is nil if there are none
+func selectEncrypter() (Encrypter, error) {
+	switch {
+	case len(*decAgeRecipients) > 0 && *decPGPRecipient != "":
+		return nil, errors.New("only one of --age-recipient and --pgp-recipient can be given")
+	case len(*decAgeRecipients) > 0:
+		return AgeEncrypter(*decAgeRecipients...)
+	case *decPGPRecipient != "":
+		b, err := ioutil.ReadFile(*decPGPRecipient)
+		if err != nil {
+			return nil, err
+		}
+		return PGPEncrypter(b)
+	}
+	return nil, nil
+}
+
+// secretNameFromFile returns the name of the secret generated from the given
+// file, which is its base name without extension (e.g. "api" for
+// "services/api.env", and "env" for ".env")
+func secretNameFromFile(name string) string {
+	base := filepath.Base(name)
+	if sn := strings.TrimSuffix(base, filepath.Ext(base)); sn != "" {
+		return sn
+	}
+	return strings.TrimPrefix(base, ".")
+}
+
+// printEncodeSecret encodes the secret, and writes the output of the encoder
+// to the output file or to STDOUT, along with the values of the secret to the
+// --helm-values file for the helm format. It returns the exit code.
+func printEncodeSecret(encoder Encoder, secret Secret) int {
+	output, err := encoder(secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+		return 1
+	}
+
+	if *encHelmValues != "" {
+		values, err := HelmValues(secret)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error in encoding: %v\n", err)
+			return 1
+		}
+		// the values are the plaintext of the secret
+		if err := writePrivateFile(*encHelmValues, values); err != nil {
+			fmt.Fprintf(os.Stderr, "writing to values file: %s\n", err)
+			return 1
+		}
+	}
+
+	return printEncodeOutput(output)
+}
+
+// printEncodeOutput writes the output of the encoder to the output file or
+// to STDOUT, and returns the exit code
+func printEncodeOutput(output []byte) int {
+	format := *encFormat
+	if format == "helm" {
+		// a helm template is a yaml file
+		format = "yaml"
+	}
+	msg, err := processEncodeOutput(output, *encOutput, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "writing to output file: %s", err)
+		return 1
+	}
+	fmt.Print(msg)
+	return 0
+}
+
 // processDecodeOutput process the output of the decoder
 func processDecodeOutput(output []byte, file string) (string, error) {
 	if file != "" {
-		err := ioutil.WriteFile(file, output, 0644)
+		err := writePrivateFile(file, output)
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf(`file "%s" created`, *decOutput), nil
+		return fmt.Sprintf(`file "%s" created`, file), nil
 	}
 	return string(output), nil
 }
@@ -150,7 +717,7 @@ func processEncodeOutput(output []byte, file, format string) (string, error) {
 		if !checkExtension(file) {
 			filename = fmt.Sprintf("%s.%s", file, format)
 		}
-		err := ioutil.WriteFile(filename, output, 0644)
+		err := writePrivateFile(filename, output)
 		if err != nil {
 			return "", err
 		}
@@ -160,20 +727,203 @@ func processEncodeOutput(output []byte, file, format string) (string, error) {
 	return string(output), nil
 }
 
-// selectDecoder returns an encoder based on the input provided.
-func selectDecoder(input string) Decoder {
-	if input != "" && strings.Contains(input, ".json") {
-		return DecodeJSON
+// writePrivateFile writes the data to the named file, readable by its owner
+// only as it holds secret values. An existing file is truncated and has its
+// permissions restricted before it is written.
+func writePrivateFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readFileSources reads the files and the files of the directories given on
+// the command line into the data of a secret
+func readFileSources(files, dirs []string) (map[string]string, error) {
+	sources := make([]map[string]string, 0, len(dirs)+1)
+	data, err := FileData(files...)
+	if err != nil {
+		return nil, err
+	}
+	sources = append(sources, data)
+	for _, dir := range dirs {
+		data, err := DirData(dir)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, data)
+	}
+	return MergeData(sources...)
+}
+
+// readFiles reads the content of the given files, leaving the content of
+// empty file names empty
+func readFiles(names ...string) ([][]byte, error) {
+	res := make([][]byte, len(names))
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		res[i] = b
+	}
+	return res, nil
+}
+
+// selectDecoder returns a decoder based on the input format provided, which
+// detects the format from the content of the input by default
+func selectDecoder(format string) (Decoder, error) {
+	if format == "auto" {
+		return DecodeAny, nil
 	}
-	return DecodeYAML
+	return InputDecoder(InputFormat(format))
 }
 
-// selectEncoder returns an encoder based on the format provided.
-func selectEncoder(format string) Encoder {
-	if format == "json" {
-		return EncodeJSON
+// selectFormatter returns the formatter of the decoded output based on the
+// format provided
+func selectFormatter(format string) Formatter {
+	switch format {
+	case "json":
+		return FormatJSON
+	case "yaml":
+		return FormatYAML
+	case "shell":
+		return FormatShell
+	case "docker":
+		return FormatDockerEnv
+	case "systemd":
+		return FormatSystemd
+	default:
+		return FormatSecrets
+	}
+}
+
+// selectEncoder returns an encoder based on the format and the data mode
+// provided, which seals the secret if a sealing certificate is given, or
+// encrypts it with SOPS if SOPS recipients are given.
+func selectEncoder(format string) (Encoder, error) {
+	key, recipients, err := readEncryptionKeys(format)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case key != nil && format == "json":
+		return SealedJSONEncoder(key), nil
+	case key != nil:
+		return SealedYAMLEncoder(key), nil
+	case recipients != nil:
+		return SOPSEncoder(DataMode(*encDataMode), *recipients)
+	case format == "helm":
+		return HelmEncoder(DataMode(*encDataMode)), nil
+	case format == "json":
+		return JSONEncoder(DataMode(*encDataMode)), nil
+	default:
+		return YAMLEncoder(DataMode(*encDataMode)), nil
+	}
+}
+
+// selectListEncoder returns a list encoder based on the format and the data
+// mode provided, which seals the secrets if a sealing certificate is given,
+// or encrypts them with SOPS if SOPS recipients are given.
+func selectListEncoder(format string) (ListEncoder, error) {
+	if format == "helm" {
+		return nil, errors.New("the helm format cannot be given with several inputs")
+	}
+	key, recipients, err := readEncryptionKeys(format)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case key != nil && format == "json":
+		return SealedJSONListEncoder(key), nil
+	case key != nil:
+		return SealedYAMLListEncoder(key), nil
+	case recipients != nil:
+		return SOPSListEncoder(DataMode(*encDataMode), *recipients)
+	case format == "json":
+		return JSONListEncoder(DataMode(*encDataMode)), nil
+	default:
+		return YAMLListEncoder(DataMode(*encDataMode)), nil
+	}
+}
+
+// readEncryptionKeys reads the sealing key and the SOPS recipients given on
+// the command line, which are nil if there are none
+func readEncryptionKeys(format string) (*rsa.PublicKey, *SOPSRecipients, error) {
+	key, err := readSealingKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading sealing certificate: %v", err)
+	}
+	recipients, err := readSOPSRecipients()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading SOPS recipients: %v", err)
+	}
+	switch {
+	case key != nil && recipients != nil:
+		return nil, nil, errors.New("--seal-cert cannot be given with --sops-age or --sops-pgp")
+	case key != nil && format == "helm":
+		return nil, nil, errors.New("--seal-cert cannot be given with the helm format")
+	case key != nil && *encDataMode != string(DataModeData):
+		return nil, nil, errors.New("--seal-cert cannot be given with --data-mode, as sealed values are always written to encryptedData")
+	case recipients != nil && format != "yaml":
+		return nil, nil, errors.New("SOPS encryption requires the yaml format")
+	}
+	return key, recipients, nil
+}
+
+// readUnsealingKey reads the private key of the sealed secrets controller
+// from the named file, which is nil if there is none
+func readUnsealingKey(name string) (*rsa.PrivateKey, error) {
+	if name == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(b)
+}
+
+// readSOPSRecipients reads the SOPS recipients given on the command line,
+// which are nil if there are none
+func readSOPSRecipients() (*SOPSRecipients, error) {
+	if len(*encSOPSAge) == 0 && *encSOPSPGP == "" {
+		return nil, nil
+	}
+	recipients := SOPSRecipients{Age: *encSOPSAge}
+	if *encSOPSPGP != "" {
+		b, err := ioutil.ReadFile(*encSOPSPGP)
+		if err != nil {
+			return nil, err
+		}
+		recipients.PGPKeyring = b
+	}
+	return &recipients, nil
+}
+
+// readSealingKey reads the public key of the sealing certificate given on
+// the command line, which is nil if there is none
+func readSealingKey() (*rsa.PublicKey, error) {
+	if *encSealCert == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(*encSealCert)
+	if err != nil {
+		return nil, err
 	}
-	return EncodeYAML
+	return ParsePublicKey(b)
 }
 
 // selectInput returns the io.Reader based on the provided input.
@@ -1,10 +1,13 @@
 package k8shhh
 
 import (
-	"encoding/base64"
+	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -14,53 +17,242 @@ import (
 // Decoder is a type for function that decodes a given io.Reader input
 type Decoder func(io.Reader) (interface{}, error)
 
-// Decode decodes the input based on the given decoder
+// Decode decodes the input based on the given decoder. The embedded docker
+// config of a kubernetes.io/dockerconfigjson secret is pretty printed with the
+// credentials of each registry broken out. When the input holds several
+// secrets, the entries of each secret are grouped under a header naming it.
+// Values which are not valid UTF-8 are written base64 encoded, followed by a
+// "# k8shhh:binary" comment. Apart from such a docker config, the output of a
+// single secret is encoded back by Encode to the same data, whatever bytes
+// its values hold.
 func Decode(input io.Reader, decoder Decoder) ([]byte, error) {
-	res, err := decoder(input)
+	objs, _, err := DecodeObjects(input, decoder)
 	if err != nil {
 		return []byte{}, err
 	}
+	return FormatDecoded(objs)
+}
 
-	var secret map[string]interface{}
+// FormatDecoded renders the data of the decoded objects, with their
+// stringData merged into their data, in the same readable format as
+// FormatSecrets
+func FormatDecoded(objs []SecretObject) ([]byte, error) {
+	secrets := make([]Secret, 0, len(objs))
+	for _, obj := range objs {
+		secrets = append(secrets, obj.Secret())
+	}
+	return FormatSecrets(secrets)
+}
 
-	switch res := res.(type) {
-	case map[interface{}]interface{}:
-		secret = convertKeysToStrings(res)
-	case map[string]interface{}:
-		secret = res
-	default:
-		return []byte{}, fmt.Errorf("unexpected type: %T", res)
+// DecodeSecrets decodes the input based on the given decoder, and returns
+// every secret it holds with its base64 decoded data. The input may be a
+// single secret, a stream of documents, or a List or SecretList as returned
+// by `kubectl get secrets`. Objects of any other kind are skipped.
+//
+// The stringData of each secret is merged into its data in the same way as
+// the API server does, with the values of stringData taking precedence. A
+// warning is returned for each key of data overridden this way.
+func DecodeSecrets(input io.Reader, decoder Decoder) ([]Secret, []string, error) {
+	return DecodeSealedSecrets(input, decoder, nil)
+}
+
+// DecodeSealedSecrets decodes the input in the same way as DecodeSecrets,
+// and also unseals the SealedSecrets it holds with the given private key of
+// the sealed secrets controller. SealedSecrets are skipped with a warning if
+// no key is given. The warnings are also returned when no secret is found.
+func DecodeSealedSecrets(input io.Reader, decoder Decoder, key *rsa.PrivateKey) ([]Secret, []string, error) {
+	objs, warnings, err := decodeObjects(input, decoder, key)
+	if err != nil {
+		return nil, warnings, err
+	}
+	secrets := make([]Secret, 0, len(objs))
+	for _, obj := range objs {
+		secrets = append(secrets, obj.Secret())
+	}
+	return secrets, warnings, nil
+}
+
+// decodeObjects decodes the input based on the given decoder, and returns
+// the objects of the secrets it holds, unsealing the SealedSecrets with the
+// given private key unless it is nil
+func decodeObjects(input io.Reader, decoder Decoder, key *rsa.PrivateKey) ([]SecretObject, []string, error) {
+	res, err := decoder(input)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	d := secret["data"]
-	if d == nil {
-		return []byte{}, nil
+	c := collector{key: key}
+	if err := c.collect(res); err != nil {
+		return nil, nil, err
+	}
+	if len(c.objects) == 0 {
+		return nil, c.warnings, errors.New("no secret found in input")
 	}
+	return c.objects, c.warnings, nil
+}
 
-	var data map[string]interface{}
+// DecodeToDir decodes the input based on the given decoder, and writes each
+// key of the secret data to its own file in the given directory, as done by
+// SecretsToDir. It returns the names of the files written.
+func DecodeToDir(input io.Reader, decoder Decoder, dir string, force bool) ([]string, error) {
+	secrets, _, err := DecodeSecrets(input, decoder)
+	if err != nil {
+		return nil, err
+	}
+	return SecretsToDir(secrets, dir, force, nil)
+}
 
-	switch d := d.(type) {
-	case map[interface{}]interface{}:
-		data = convertKeysToStrings(d)
-	case map[string]interface{}:
-		data = d
-	default:
-		return []byte{}, fmt.Errorf("unexpected type: %T", d)
+// DecodeToFiles decodes the input based on the given decoder, and writes the
+// readable format of each secret to its own file in the given directory, as
+// done by SecretsToFiles. It returns the names of the files written.
+func DecodeToFiles(input io.Reader, decoder Decoder, dir string, force bool) ([]string, error) {
+	secrets, _, err := DecodeSecrets(input, decoder)
+	if err != nil {
+		return nil, err
 	}
+	return SecretsToFiles(secrets, dir, force, nil)
+}
 
-	processed := convertValuesToStrings(data)
+// FormatSecrets renders the data of the secrets in a readable format. When
+// there are several secrets, the entries of each secret are grouped under a
+// header naming it.
+func FormatSecrets(secrets []Secret) ([]byte, error) {
+	if len(secrets) == 1 {
+		return formatSecret(secrets[0])
+	}
 
-	for k, v := range processed {
-		l, err := base64.StdEncoding.DecodeString(v)
+	groups := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		out, err := formatSecret(secret)
 		if err != nil {
 			return []byte{}, err
 		}
-		processed[k] = string(l)
+		group := fmt.Sprintf("# %s", qualifiedSecretName(secret))
+		if len(out) > 0 {
+			group += "\n" + string(out)
+		}
+		groups = append(groups, group)
+	}
+
+	return []byte(strings.Join(groups, "\n\n")), nil
+}
+
+// SecretsToDir writes each key of the secret data to its own file in the
+// given directory, which is created if needed. When there are several
+// secrets, the keys of each secret are written to a subdirectory named after
+// it. Existing files are only overwritten when force is set, and the content
+// of the files is encrypted with the given encrypter unless it is nil. It
+// returns the names of the files written.
+func SecretsToDir(secrets []Secret, dir string, force bool, encrypt Encrypter) ([]string, error) {
+	files := make(map[string]string)
+	dirs := make(map[string]bool)
+	for _, secret := range secrets {
+		secretDir := dir
+		if len(secrets) > 1 {
+			if err := validateFileName(secret.Name); err != nil {
+				return nil, fmt.Errorf("invalid secret name %q: %v", secret.Name, err)
+			}
+			if dirs[secret.Name] {
+				return nil, fmt.Errorf("duplicate secret %q", secret.Name)
+			}
+			dirs[secret.Name] = true
+			secretDir = filepath.Join(dir, secret.Name)
+		}
+		for k, v := range secret.Data {
+			if err := validateFileName(k); err != nil {
+				return nil, fmt.Errorf("invalid key %q: %v", k, err)
+			}
+			files[filepath.Join(secretDir, k)] = v
+		}
+	}
+
+	return writeFiles(files, force, encrypt)
+}
+
+// SecretsToFiles writes the readable format of each secret to its own file
+// in the given directory, which is created if needed. The files are named
+// after the secrets with an .env extension. Existing files are only
+// overwritten when force is set, and the content of the files is encrypted
+// with the given encrypter unless it is nil. It returns the names of the
+// files written.
+func SecretsToFiles(secrets []Secret, dir string, force bool, encrypt Encrypter) ([]string, error) {
+	files := make(map[string]string)
+	for _, secret := range secrets {
+		if err := validateFileName(secret.Name); err != nil {
+			return nil, fmt.Errorf("invalid secret name %q: %v", secret.Name, err)
+		}
+		name := filepath.Join(dir, secret.Name+".env")
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("duplicate secret %q", secret.Name)
+		}
+		out, err := formatSecret(secret)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = string(out)
+	}
+
+	return writeFiles(files, force, encrypt)
+}
+
+// writeFiles writes the content of each named file, creating their
+// directories if needed, and encrypting the content unless the encrypter is
+// nil. Nothing is written if one of the files already exists, unless force
+// is set. It returns the names of the files written.
+func writeFiles(files map[string]string, force bool, encrypt Encrypter) ([]string, error) {
+	names := sortedKeys(files)
+	if !force {
+		for _, name := range names {
+			if _, err := os.Lstat(name); err == nil {
+				return nil, fmt.Errorf("file %q already exists", name)
+			}
+		}
+	}
+	if encrypt != nil {
+		for _, name := range names {
+			b, err := encrypt([]byte(files[name]))
+			if err != nil {
+				return nil, fmt.Errorf("encrypting %q: %v", name, err)
+			}
+			files[name] = string(b)
+		}
+	}
+
+	written := make([]string, 0, len(names))
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			return written, err
+		}
+		// existing files are removed rather than truncated, so that the new
+		// file is created with restricted permissions and symlinks are not
+		// followed
+		if force {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return written, err
+			}
+		}
+		if err := writeFile(name, []byte(files[name])); err != nil {
+			return written, err
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
+
+// formatSecret renders the data of the secret in a dotenv format, which
+// ParseSecret reads back to the same data. The docker config of a
+// kubernetes.io/dockerconfigjson secret is pretty printed instead, unless the
+// secret holds other keys which would be lost.
+func formatSecret(secret Secret) ([]byte, error) {
+	if secret.Type == SecretTypeDockerConfigJSON && len(secret.Data) == 1 {
+		if config, ok := secret.Data[DockerConfigJSONKey]; ok {
+			return formatDockerConfig(config)
+		}
 	}
 
-	lines := make([]string, 0, len(processed))
-	for k, v := range processed {
-		lines = append(lines, fmt.Sprintf(`%s=%s`, k, doubleQuoteEscape(v)))
+	lines := make([]string, 0, len(secret.Data))
+	for k, v := range secret.Data {
+		lines = append(lines, fmt.Sprintf(`%s=%s`, k, quoteDotenvValue(v)))
 	}
 	sort.Strings(lines)
 	out := strings.Join(lines, "\n")
@@ -68,6 +260,92 @@ func Decode(input io.Reader, decoder Decoder) ([]byte, error) {
 	return []byte(out), nil
 }
 
+// qualifiedSecretName returns the name of the secret, prefixed by its
+// namespace if it has one
+func qualifiedSecretName(secret Secret) string {
+	if secret.Namespace == "" {
+		return secret.Name
+	}
+	return secret.Namespace + "/" + secret.Name
+}
+
+// collector gathers the secrets found in decoded documents, along with the
+// warnings raised while decoding them
+type collector struct {
+	key      *rsa.PrivateKey
+	objects  []SecretObject
+	warnings []string
+}
+
+// collect adds the secrets found in the decoded document. A document is
+// either a single object, a list of documents, or a List or SecretList whose
+// items are collected in turn.
+func (c *collector) collect(doc interface{}) error {
+	switch doc := doc.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for _, d := range doc {
+			if err := c.collect(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	object, err := toStringMap(doc)
+	if err != nil {
+		return err
+	}
+
+	switch kind := stringValue(object["kind"]); kind {
+	case "List", "SecretList":
+		items, ok := object["items"].([]interface{})
+		if !ok && object["items"] != nil {
+			return fmt.Errorf("unexpected type: %T", object["items"])
+		}
+		return c.collect(items)
+	case "SealedSecret":
+		if c.key == nil {
+			c.warnings = append(c.warnings, fmt.Sprintf("skipping sealed secret %q, as no private key is given", objectName(object)))
+			return nil
+		}
+		secret, err := decodeSealedSecret(object, c.key)
+		if err != nil {
+			return fmt.Errorf("sealed secret %q: %v", objectName(object), err)
+		}
+		obj, err := NewSecretObject(secret, DataModeData)
+		if err != nil {
+			return err
+		}
+		c.objects = append(c.objects, obj)
+	case "", "Secret":
+		obj, err := decodeObject(object)
+		if err != nil {
+			return err
+		}
+		secret, overridden := obj.secret()
+		for _, k := range overridden {
+			c.warnings = append(c.warnings, fmt.Sprintf("secret %q: key %q of data is overridden by stringData", qualifiedSecretName(secret), k))
+		}
+		c.objects = append(c.objects, obj)
+	}
+	return nil
+}
+
+// decodeSecret reads the metadata, the type and the data of the given secret
+// object. The base64 decoded data is merged with the stringData, which takes
+// precedence, and the keys of data overridden this way are returned. The
+// data is nil if the secret has neither.
+func decodeSecret(object map[string]interface{}) (Secret, []string, error) {
+	obj, err := decodeObject(object)
+	if err != nil {
+		return Secret{}, nil, err
+	}
+	secret, overridden := obj.secret()
+	return secret, overridden, nil
+}
+
 // DecodeJSON decodes the json formatted input into the readable secret
 func DecodeJSON(input io.Reader) (interface{}, error) {
 	var res interface{}
@@ -77,13 +355,74 @@ func DecodeJSON(input io.Reader) (interface{}, error) {
 	return res, nil
 }
 
-// DecodeYAML decodes the yaml formatted input into the readable secret
+// DecodeYAML decodes the yaml formatted input into the readable secret. An
+// input holding several documents separated by "---" is decoded into a
+// []interface{} of the documents.
 func DecodeYAML(input io.Reader) (interface{}, error) {
-	var res interface{}
-	if err := yaml.NewDecoder(input).Decode(&res); err != nil {
+	var docs []interface{}
+	decoder := yaml.NewDecoder(input)
+	for {
+		var res interface{}
+		err := decoder.Decode(&res)
+		if err == io.EOF && len(docs) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, res)
+	}
+	if len(docs) == 1 {
+		return docs[0], nil
+	}
+	return docs, nil
+}
+
+// objectName returns the name of the given decoded object, prefixed by its
+// namespace if it has one
+func objectName(object map[string]interface{}) string {
+	metadata, err := toStringMap(object["metadata"])
+	if err != nil {
+		return ""
+	}
+	return qualifiedSecretName(Secret{
+		Name:      stringValue(metadata["name"]),
+		Namespace: stringValue(metadata["namespace"]),
+	})
+}
+
+// toStringMap returns the given decoded object as a map with string keys
+func toStringMap(v interface{}) (map[string]interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		return convertKeysToStrings(v), nil
+	case map[string]interface{}:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unexpected type: %T", v)
+	}
+}
+
+// stringValue returns the given decoded value as a string, which is empty if
+// the value is missing
+func stringValue(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// stringMapValue returns the given decoded map with its keys and values
+// converted to strings, which is nil if the map is missing
+func stringMapValue(v interface{}) (map[string]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, err := toStringMap(v)
+	if err != nil {
 		return nil, err
 	}
-	return res, nil
+	return convertValuesToStrings(m), nil
 }
 
 // convertKeysToStrings converts the keys of a given map to strings
@@ -108,6 +447,32 @@ func convertValuesToStrings(m map[string]interface{}) map[string]string {
 	return res
 }
 
+// validateFileName checks that the key can be used as a file name without
+// escaping the directory it is written to
+func validateFileName(key string) error {
+	if key == "" || key == "." || key == ".." {
+		return fmt.Errorf("must not be empty, '.' or '..'")
+	}
+	if strings.ContainsAny(key, `/\`) || strings.Contains(key, "..") {
+		return fmt.Errorf("must not contain a path separator or '..'")
+	}
+	return nil
+}
+
+// writeFile writes the data to the named file, which must not exist yet and
+// is only readable by its owner
+func writeFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // doubleQuoteEscape is a helper function for escaping double quotes
 func doubleQuoteEscape(line string) string {
 	for _, c := range "\\\n\r\"!$`" {
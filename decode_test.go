@@ -3,6 +3,9 @@ package k8shhh
 import (
 	"errors"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -72,6 +75,60 @@ func TestDecode(t *testing.T) {
 			name:    "yaml-one",
 			res:     "a=b",
 		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestDockerConfigJSON),
+			decoder: DecodeYAML,
+			name:    "yaml-dockerconfigjson",
+			res:     successFormatDockerConfigTest,
+		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestDockerConfigJSONExtra),
+			decoder: DecodeYAML,
+			name:    "yaml-dockerconfigjson-extra",
+			res:     `.dockerconfigjson="{\"auths\":{\"registry.example.com\":{\"auth\":\"dXNlcjpwYXNz\"}}}"` + "\nextra=x",
+		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestMulti),
+			decoder: DecodeYAML,
+			name:    "yaml-multi",
+			res:     "# yaml-one\na=b\n\n# production/yaml-files\na=b\ntls.crt=cert",
+		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestList),
+			decoder: DecodeYAML,
+			name:    "yaml-list",
+			res:     "# production/yaml-one\na=b\n\n# production/yaml-empty",
+		},
+		{
+			input:   strings.NewReader(successDecodeJSONTestSecretList),
+			decoder: DecodeJSON,
+			name:    "json-secret-list",
+			res:     "a=b",
+		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestStringData),
+			decoder: DecodeYAML,
+			name:    "yaml-string-data",
+			res:     "a=b\nport=5432",
+		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestMerged),
+			decoder: DecodeYAML,
+			name:    "yaml-merged",
+			res:     "a=c\nb=d\ne=f",
+		},
+		{
+			input:   strings.NewReader("data:\n  a: /w8A\n  b: YyBk\n"),
+			decoder: DecodeYAML,
+			name:    "yaml-binary",
+			res:     "a=/w8A # k8shhh:binary\nb=\"c d\"",
+		},
+		{
+			input:   strings.NewReader(errorDecodeYAMLTestNoSecret),
+			decoder: DecodeYAML,
+			name:    "yaml-no-secret",
+			err:     errors.New("no secret found in input"),
+		},
 	}
 
 	for _, test := range tests {
@@ -94,6 +151,297 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+// TestDecodeToDir tests the DecodeToDir function
+func TestDecodeToDir(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "k8shhh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFile(t, filepath.Join(dir, "existing", "a"), "old")
+
+	tests := []struct {
+		input io.Reader
+		name  string
+		dir   string
+		force bool
+		res   map[string]string
+		err   error
+	}{
+		{
+			input: strings.NewReader(successDecodeYAMLTestFiles),
+			name:  "new-dir",
+			dir:   filepath.Join(dir, "new", "dir"),
+			res:   map[string]string{"a": "b", "tls.crt": "cert"},
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestOne),
+			name:  "existing",
+			dir:   filepath.Join(dir, "existing"),
+			err:   errors.New(`file "` + filepath.Join(dir, "existing", "a") + `" already exists`),
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestOne),
+			name:  "existing-force",
+			dir:   filepath.Join(dir, "existing"),
+			force: true,
+			res:   map[string]string{"a": "b"},
+		},
+		{
+			input: strings.NewReader(errorDecodeYAMLTestTraversal),
+			name:  "traversal",
+			dir:   filepath.Join(dir, "traversal"),
+			err:   errors.New(`invalid key "../a": must not contain a path separator or '..'`),
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestMulti),
+			name:  "multi",
+			dir:   filepath.Join(dir, "multi"),
+			res:   map[string]string{"yaml-one/a": "b", "yaml-files/a": "b", "yaml-files/tls.crt": "cert"},
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestOne + "---\n" + successDecodeYAMLTestOne),
+			name:  "multi-duplicate",
+			dir:   filepath.Join(dir, "multi-duplicate"),
+			err:   errors.New(`duplicate secret "yaml-one"`),
+		},
+		{
+			input: strings.NewReader("data:\n  keystore.p12: /w8A\n"),
+			name:  "binary",
+			dir:   filepath.Join(dir, "binary"),
+			res:   map[string]string{"keystore.p12": "\xff\x0f\x00"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			files, err := DecodeToDir(test.input, DecodeYAML, test.dir, test.force)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if len(files) != len(test.res) {
+				t.Fatalf("expected %d files but got %q", len(test.res), files)
+			}
+			for k, v := range test.res {
+				b, err := ioutil.ReadFile(filepath.Join(test.dir, k))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != v {
+					t.Fatalf("expected file %q to be %q but got %q", k, v, b)
+				}
+			}
+		})
+	}
+}
+
+// TestDecodeToFiles tests the DecodeToFiles function
+func TestDecodeToFiles(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "k8shhh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFile(t, filepath.Join(dir, "existing", "yaml-one.env"), "old")
+
+	tests := []struct {
+		input io.Reader
+		name  string
+		dir   string
+		force bool
+		res   map[string]string
+		err   error
+	}{
+		{
+			input: strings.NewReader(successDecodeYAMLTestMulti),
+			name:  "multi",
+			dir:   filepath.Join(dir, "multi"),
+			res:   map[string]string{"yaml-one.env": "a=b", "yaml-files.env": "a=b\ntls.crt=cert"},
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestOne),
+			name:  "existing",
+			dir:   filepath.Join(dir, "existing"),
+			err:   errors.New(`file "` + filepath.Join(dir, "existing", "yaml-one.env") + `" already exists`),
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestOne),
+			name:  "existing-force",
+			dir:   filepath.Join(dir, "existing"),
+			force: true,
+			res:   map[string]string{"yaml-one.env": "a=b"},
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestOne + "---\n" + successDecodeYAMLTestOne),
+			name:  "duplicate",
+			dir:   filepath.Join(dir, "duplicate"),
+			err:   errors.New(`duplicate secret "yaml-one"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			files, err := DecodeToFiles(test.input, DecodeYAML, test.dir, test.force)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if len(files) != len(test.res) {
+				t.Fatalf("expected %d files but got %q", len(test.res), files)
+			}
+			for k, v := range test.res {
+				b, err := ioutil.ReadFile(filepath.Join(test.dir, k))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != v {
+					t.Fatalf("expected file %q to be %q but got %q", k, v, b)
+				}
+			}
+		})
+	}
+}
+
+// TestDecodeSecrets tests the DecodeSecrets function
+func TestDecodeSecrets(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input    io.Reader
+		decoder  Decoder
+		name     string
+		res      []Secret
+		warnings []string
+		err      error
+	}{
+		{
+			input:   strings.NewReader(successDecodeYAMLTestMulti),
+			decoder: DecodeYAML,
+			name:    "yaml-multi",
+			res: []Secret{
+				{Name: "yaml-one", Type: SecretTypeOpaque, Data: map[string]string{"a": "b"}},
+				{Name: "yaml-files", Namespace: "production", Labels: map[string]string{"app": "web"}, Type: SecretTypeOpaque, Data: map[string]string{"a": "b", "tls.crt": "cert"}},
+			},
+		},
+		{
+			input:   strings.NewReader(successDecodeJSONTestSecretList),
+			decoder: DecodeJSON,
+			name:    "json-secret-list",
+			res: []Secret{
+				{Name: "json-one", Namespace: "default", Type: SecretTypeOpaque, Data: map[string]string{"a": "b"}},
+			},
+		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestStringData),
+			decoder: DecodeYAML,
+			name:    "yaml-string-data",
+			res: []Secret{
+				{Name: "yaml-string-data", Type: SecretTypeOpaque, Data: map[string]string{"a": "b", "port": "5432"}},
+			},
+		},
+		{
+			input:   strings.NewReader(successDecodeYAMLTestMerged),
+			decoder: DecodeYAML,
+			name:    "yaml-merged",
+			res: []Secret{
+				{Name: "yaml-merged", Namespace: "production", Type: SecretTypeOpaque, Data: map[string]string{"a": "c", "b": "d", "e": "f"}},
+			},
+			warnings: []string{`secret "production/yaml-merged": key "a" of data is overridden by stringData`},
+		},
+		{
+			input:   strings.NewReader(`{"kind": "List", "items": "a"}`),
+			decoder: DecodeJSON,
+			name:    "json-list-error",
+			err:     errors.New("unexpected type: string"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, warnings, err := DecodeSecrets(test.input, test.decoder)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+			}
+			if !reflect.DeepEqual(warnings, test.warnings) {
+				t.Fatalf("expected warnings to be %q but got %q", test.warnings, warnings)
+			}
+		})
+	}
+}
+
+// TestFormatDecoded tests the FormatDecoded function along with
+// DecodeObjects
+func TestFormatDecoded(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input io.Reader
+		name  string
+		res   string
+	}{
+		{
+			input: strings.NewReader(successDecodeYAMLTestMulti),
+			name:  "multi",
+			res:   "# yaml-one\na=b\n\n# production/yaml-files\na=b\ntls.crt=cert",
+		},
+		{
+			input: strings.NewReader("data:\n  keystore.p12: /w8A\n"),
+			name:  "binary",
+			res:   "keystore.p12=/w8A # k8shhh:binary",
+		},
+		{
+			input: strings.NewReader("data:\n  a: Yg==\n  b: Yw==\nstringData:\n  a: d\n"),
+			name:  "string-data",
+			res:   "a=d\nb=c",
+		},
+		{
+			input: strings.NewReader(successDecodeYAMLTestEmpty),
+			name:  "empty",
+			res:   "",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			objs, _, err := DecodeObjects(test.input, DecodeYAML)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := FormatDecoded(objs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
 // TestDecodeJSON tests the DecodeJSON function
 func TestDecodeJSON(t *testing.T) {
 	t.Parallel()
@@ -154,6 +502,16 @@ func TestDecodeYAML(t *testing.T) {
 			name:  "decode-yaml-success",
 			res:   map[string]interface{}{"a": "b"},
 		},
+		{
+			input: strings.NewReader(""),
+			name:  "decode-yaml-empty",
+			err:   errors.New("EOF"),
+		},
+		{
+			input: strings.NewReader("a: b\n---\nc: d\n"),
+			name:  "decode-yaml-multi",
+			res:   []interface{}{map[interface{}]interface{}{"a": "b"}, map[interface{}]interface{}{"c": "d"}},
+		},
 	}
 
 	for _, test := range tests {
@@ -186,6 +544,40 @@ data:
   a: 世界
 `
 
+	errorDecodeYAMLTestTraversal = `apiVersion: v1
+kind: Secret
+metadata:
+  name: traversal
+type: Opaque
+data:
+  ../a: Yg==
+`
+
+	errorDecodeYAMLTestNoSecret = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  a: b
+`
+
+	successDecodeJSONTestSecretList = `{
+	"apiVersion": "v1",
+	"kind": "SecretList",
+	"items": [
+		{
+			"data": {
+				"a": "Yg=="
+			},
+			"metadata": {
+				"name": "json-one",
+				"namespace": "default"
+			},
+			"type": "Opaque"
+		}
+	]
+}`
+
 	successDecodeJSONTestEmpty = `{
 	"apiVersion": "v1",
 	"data": {},
@@ -232,4 +624,110 @@ type: Opaque
 data:
   a: Yg==
 `
+
+	successDecodeYAMLTestStringData = `apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-string-data
+type: Opaque
+stringData:
+  a: b
+  port: 5432
+`
+
+	successDecodeYAMLTestMerged = `apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-merged
+  namespace: production
+type: Opaque
+data:
+  a: Yg==
+  b: ZA==
+stringData:
+  a: c
+  e: f
+`
+
+	successDecodeYAMLTestFiles = `apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-files
+type: Opaque
+data:
+  a: Yg==
+  tls.crt: Y2VydA==
+`
+
+	successDecodeYAMLTestMulti = `apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-one
+type: Opaque
+data:
+  a: Yg==
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  a: b
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-files
+  namespace: production
+  labels:
+    app: web
+type: Opaque
+data:
+  a: Yg==
+  tls.crt: Y2VydA==
+`
+
+	successDecodeYAMLTestList = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: yaml-one
+    namespace: production
+  type: Opaque
+  data:
+    a: Yg==
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+  data:
+    a: b
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: yaml-empty
+    namespace: production
+  type: Opaque
+`
+
+	successDecodeYAMLTestDockerConfigJSON = `apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-dockerconfigjson
+type: kubernetes.io/dockerconfigjson
+data:
+  .dockerconfigjson: eyJhdXRocyI6eyJyZWdpc3RyeS5leGFtcGxlLmNvbSI6eyJhdXRoIjoiZFhObGNqcHdZWE56In19fQ==
+`
+
+	successDecodeYAMLTestDockerConfigJSONExtra = `apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-dockerconfigjson
+type: kubernetes.io/dockerconfigjson
+data:
+  .dockerconfigjson: eyJhdXRocyI6eyJyZWdpc3RyeS5leGFtcGxlLmNvbSI6eyJhdXRoIjoiZFhObGNqcHdZWE56In19fQ==
+  extra: eA==
+`
 )
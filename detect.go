@@ -0,0 +1,239 @@
+package k8shhh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// InputFormat is the format of the input of a decoder
+type InputFormat string
+
+// The input formats detected by DetectInputFormat
+const (
+	InputFormatJSON   InputFormat = "json"
+	InputFormatYAML   InputFormat = "yaml"
+	InputFormatDotenv InputFormat = "dotenv"
+)
+
+// dotenvLineRegexp matches the start of an assignment of a dotenv file
+var dotenvLineRegexp = regexp.MustCompile(`^\s*(export\s+)?[A-Za-z_][A-Za-z0-9_.-]*\s*=`)
+
+// secretFields are the top level fields of the objects handled by the
+// decoders, which a bare data map has none of
+var secretFields = []string{"apiVersion", "kind", "metadata", "data", "stringData", "type", "immutable", "items", "spec"}
+
+// DecodeAny decodes the input after detecting its format with
+// DetectInputFormat. Besides secrets and lists of secrets in json or yaml,
+// it accepts a bare data map of base64 encoded values, as printed by
+// `kubectl get secret -o jsonpath='{.data}'`, and a dotenv file, whose
+// values are taken as is.
+func DecodeAny(input io.Reader) (interface{}, error) {
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	format, err := DetectInputFormat(b)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := InputDecoder(format)
+	if err != nil {
+		return nil, err
+	}
+	return decoder(bytes.NewReader(b))
+}
+
+// InputDecoder returns the decoder of the given input format. The json and
+// yaml decoders also accept a bare data map of base64 encoded values.
+func InputDecoder(format InputFormat) (Decoder, error) {
+	switch format {
+	case InputFormatJSON:
+		return dataMapDecoder(DecodeJSON), nil
+	case InputFormatYAML:
+		return dataMapDecoder(DecodeYAML), nil
+	case InputFormatDotenv:
+		return DecodeDotenv, nil
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+}
+
+// DecodeDotenv decodes the dotenv formatted input into a secret whose data
+// is the values of the input as is
+func DecodeDotenv(input io.Reader) (interface{}, error) {
+	data, err := parseDotenv(input)
+	if err != nil {
+		return nil, err
+	}
+	stringData := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		stringData[k] = v
+	}
+	return map[string]interface{}{"stringData": stringData}, nil
+}
+
+// DetectInputFormat sniffs the format of the input, which is either json, a
+// stream of yaml documents or a dotenv file. An error is returned when the
+// format cannot be told, such as for a map of plain values which is both
+// valid yaml and a valid dotenv file.
+func DetectInputFormat(input []byte) (InputFormat, error) {
+	trimmed := bytes.TrimSpace(input)
+	if len(trimmed) == 0 {
+		return "", errors.New("cannot detect the input format of an empty input")
+	}
+
+	docs, yamlErr := decodeYAMLDocuments(input)
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, new(interface{})); err == nil {
+			return InputFormatJSON, nil
+		} else if yamlErr != nil {
+			return "", fmt.Errorf("input looks like json, but is invalid: %v", err)
+		}
+		// yaml flow mappings also start with a brace
+		return InputFormatYAML, nil
+	}
+
+	isMaps := yamlErr == nil
+	for _, doc := range docs {
+		if _, err := toStringMap(doc); doc != nil && err != nil {
+			isMaps = false
+		}
+	}
+	isDotenv := looksLikeDotenv(input)
+
+	switch {
+	case isMaps && isDotenv && !dotenvKeys(docs):
+		return "", errors.New("cannot detect the input format, which is valid as both yaml and dotenv")
+	case isMaps && isDotenv:
+		// the values of the dotenv file hold ": ", which makes each line a
+		// yaml map of a key holding "="
+		return InputFormatDotenv, nil
+	case isMaps:
+		if isPlainMap(docs) && !isDataMap(docs) {
+			if _, err := parseDotenv(bytes.NewReader(input)); err == nil {
+				return "", errors.New("cannot detect the input format of a map of values which are not base64 encoded, which may be either a yaml data map or a dotenv file")
+			}
+		}
+		return InputFormatYAML, nil
+	case isDotenv:
+		return InputFormatDotenv, nil
+	case yamlErr != nil:
+		return "", fmt.Errorf("cannot detect the input format, which is neither a dotenv file nor valid yaml: %v", yamlErr)
+	default:
+		return "", errors.New("cannot detect the input format, which is neither json, yaml nor a dotenv file")
+	}
+}
+
+// dataMapDecoder returns a decoder which decodes a bare data map of base64
+// encoded values with the given decoder into a secret holding the map as its
+// data
+func dataMapDecoder(decoder Decoder) Decoder {
+	return func(input io.Reader) (interface{}, error) {
+		res, err := decoder(input)
+		if err != nil {
+			return nil, err
+		}
+		if isPlainMap([]interface{}{res}) {
+			return map[string]interface{}{"data": res}, nil
+		}
+		return res, nil
+	}
+}
+
+// decodeYAMLDocuments decodes every document of the yaml input
+func decodeYAMLDocuments(input []byte) ([]interface{}, error) {
+	res, err := DecodeYAML(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	if docs, ok := res.([]interface{}); ok {
+		return docs, nil
+	}
+	return []interface{}{res}, nil
+}
+
+// looksLikeDotenv checks whether the first line of the input which is
+// neither blank nor a comment is a dotenv assignment, and whether the input
+// can be parsed as a dotenv file
+func looksLikeDotenv(input []byte) bool {
+	for _, line := range strings.Split(string(input), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !dotenvLineRegexp.MatchString(line) {
+			return false
+		}
+		_, err := parseDotenv(bytes.NewReader(input))
+		return err == nil
+	}
+	return false
+}
+
+// dotenvKeys checks whether every top level key of the yaml documents holds
+// "=", which happens when the lines of a dotenv file are read as yaml
+func dotenvKeys(docs []interface{}) bool {
+	for _, doc := range docs {
+		object, err := toStringMap(doc)
+		if err != nil {
+			continue
+		}
+		for k := range object {
+			if !strings.Contains(k, "=") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isPlainMap checks whether the single document is a non-empty map of
+// scalar values, with none of the fields of the objects handled by the
+// decoders
+func isPlainMap(docs []interface{}) bool {
+	if len(docs) != 1 {
+		return false
+	}
+	object, err := toStringMap(docs[0])
+	if err != nil || len(object) == 0 {
+		return false
+	}
+	for _, f := range secretFields {
+		if _, ok := object[f]; ok {
+			return false
+		}
+	}
+	for _, v := range object {
+		switch v.(type) {
+		case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// isDataMap checks whether the values of the plain map of the single
+// document are all base64 encoded
+func isDataMap(docs []interface{}) bool {
+	object, err := toStringMap(docs[0])
+	if err != nil {
+		return false
+	}
+	for _, v := range object {
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+package k8shhh
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDetectInputFormat tests the DetectInputFormat function
+func TestDetectInputFormat(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		res   InputFormat
+		err   error
+	}{
+		{name: "json", input: successDecodeJSONTestOne, res: InputFormatJSON},
+		{name: "json-list", input: successDecodeJSONTestSecretList, res: InputFormatJSON},
+		{name: "json-data-map", input: `{"a": "Yg=="}`, res: InputFormatJSON},
+		{name: "yaml", input: successDecodeYAMLTestOne, res: InputFormatYAML},
+		{name: "yaml-multi", input: successDecodeYAMLTestMulti, res: InputFormatYAML},
+		{name: "yaml-flow", input: "{kind: Secret, data: {a: Yg==}}", res: InputFormatYAML},
+		{name: "yaml-data-map", input: "a: Yg==\nc: ZA==\n", res: InputFormatYAML},
+		{name: "yaml-data-only", input: "data:\n  a: Yg==\n", res: InputFormatYAML},
+		{name: "dotenv", input: "# comment\nDB_HOST=localhost\nexport DB_PORT=5432\n", res: InputFormatDotenv},
+		{name: "dotenv-one-line", input: "a=b", res: InputFormatDotenv},
+		{name: "dotenv-colon-value", input: "MESSAGE=hello: world\n", res: InputFormatDotenv},
+		{
+			name:  "empty",
+			input: " \n",
+			err:   errors.New("cannot detect the input format of an empty input"),
+		},
+		{
+			name:  "invalid-json",
+			input: `{"kind": "Secret"`,
+			err:   errors.New("input looks like json, but is invalid: unexpected end of JSON input"),
+		},
+		{
+			name:  "invalid-yaml",
+			input: "value: -",
+			err:   errors.New("cannot detect the input format, which is neither a dotenv file nor valid yaml: yaml: block sequence entries are not allowed in this context"),
+		},
+		{
+			name:  "scalar",
+			input: "-1",
+			err:   errors.New("cannot detect the input format, which is neither json, yaml nor a dotenv file"),
+		},
+		{
+			name:  "ambiguous-plain-map",
+			input: "DB_HOST: localhost\nDB_PORT: 5432\n",
+			err:   errors.New("cannot detect the input format of a map of values which are not base64 encoded, which may be either a yaml data map or a dotenv file"),
+		},
+		{
+			name:  "ambiguous-keys",
+			input: "a=b: c\nd: e\n",
+			err:   errors.New("cannot detect the input format, which is valid as both yaml and dotenv"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := DetectInputFormat([]byte(test.input))
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if res != test.res {
+					t.Fatalf("expected response to be %q but got %q", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestDecodeAny tests the DecodeAny function along with DecodeSecrets
+func TestDecodeAny(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		res   []Secret
+		err   error
+	}{
+		{
+			name:  "json",
+			input: successDecodeJSONTestOne,
+			res:   []Secret{{Name: "json-one", Type: SecretTypeOpaque, Data: map[string]string{"a": "b"}}},
+		},
+		{
+			name:  "json-data-map",
+			input: `{"a": "Yg==", "c": "ZA=="}`,
+			res:   []Secret{{Data: map[string]string{"a": "b", "c": "d"}}},
+		},
+		{
+			name:  "yaml-data-map",
+			input: "a: Yg==\n",
+			res:   []Secret{{Data: map[string]string{"a": "b"}}},
+		},
+		{
+			name:  "dotenv",
+			input: "DB_HOST=localhost\nMESSAGE=\"hello: world\"\n",
+			res:   []Secret{{Data: map[string]string{"DB_HOST": "localhost", "MESSAGE": "hello: world"}}},
+		},
+		{
+			name:  "ambiguous",
+			input: "DB_HOST: localhost\n",
+			err:   errors.New("cannot detect the input format of a map of values which are not base64 encoded, which may be either a yaml data map or a dotenv file"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, _, err := DecodeSecrets(strings.NewReader(test.input), DecodeAny)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestInputDecoder tests the InputDecoder function with an explicit format
+func TestInputDecoder(t *testing.T) {
+	t.Parallel()
+	decoder, err := InputDecoder(InputFormatDotenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := DecodeSecrets(strings.NewReader("DB_HOST: localhost\n"), decoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Secret{{Data: map[string]string{"DB_HOST": "localhost"}}}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected response to be %+v but got %+v", expected, res)
+	}
+	if _, err := InputDecoder("toml"); err == nil || err.Error() != `unknown input format "toml"` {
+		t.Fatalf("expected error to be %q but got %q", `unknown input format "toml"`, err)
+	}
+}
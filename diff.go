@@ -0,0 +1,106 @@
+package k8shhh
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChangeType is the kind of change of a key between two versions of a secret
+type ChangeType string
+
+// The kinds of change reported by DiffData
+const (
+	ChangeAdded   ChangeType = "added"
+	ChangeRemoved ChangeType = "removed"
+	ChangeChanged ChangeType = "changed"
+)
+
+// changeSymbols are the symbols prefixing each kind of change in the output
+// of FormatChanges
+var changeSymbols = map[ChangeType]string{
+	ChangeAdded:   "+",
+	ChangeRemoved: "-",
+	ChangeChanged: "~",
+}
+
+// Change is a key whose value differs between two versions of a secret. Old
+// is empty for an added key, and New for a removed one.
+type Change struct {
+	Key  string
+	Type ChangeType
+	Old  string
+	New  string
+}
+
+// DiffData compares the old and the new data of a secret, and returns the
+// keys added, removed and changed, sorted by key
+func DiffData(oldData, newData map[string]string) []Change {
+	keys := make(map[string]string, len(oldData)+len(newData))
+	for k := range oldData {
+		keys[k] = ""
+	}
+	for k := range newData {
+		keys[k] = ""
+	}
+
+	var changes []Change
+	for _, k := range sortedKeys(keys) {
+		o, inOld := oldData[k]
+		n, inNew := newData[k]
+		switch {
+		case !inOld:
+			changes = append(changes, Change{Key: k, Type: ChangeAdded, New: n})
+		case !inNew:
+			changes = append(changes, Change{Key: k, Type: ChangeRemoved, Old: o})
+		case o != n:
+			changes = append(changes, Change{Key: k, Type: ChangeChanged, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// FormatChanges renders the changes one per line, prefixed with "+" for an
+// added key, "-" for a removed one and "~" for a changed one. Values are
+// masked as a prefix of their SHA-256 hash and their length, unless reveal is
+// set, in which case the values which are not valid UTF-8 are shown base64
+// encoded after a !!binary marker.
+func FormatChanges(changes []Change, reveal bool) []byte {
+	show := maskValue
+	if reveal {
+		show = func(v string) string {
+			if !utf8.ValidString(v) {
+				return "!!binary " + base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			return `"` + doubleQuoteEscape(v) + `"`
+		}
+	}
+
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		var value string
+		switch c.Type {
+		case ChangeAdded:
+			value = show(c.New)
+		case ChangeRemoved:
+			value = show(c.Old)
+		default:
+			value = fmt.Sprintf("%s -> %s", show(c.Old), show(c.New))
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", changeSymbols[c.Type], c.Key, value))
+	}
+	if len(lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// maskValue returns the first 12 hex digits of the SHA-256 hash of the value
+// along with its length, which tell values apart without revealing them
+func maskValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return fmt.Sprintf("sha256:%s (%d bytes)", hex.EncodeToString(sum[:])[:12], len(value))
+}
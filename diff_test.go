@@ -0,0 +1,108 @@
+package k8shhh
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDiffData tests the DiffData function
+func TestDiffData(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		old  map[string]string
+		new  map[string]string
+		res  []Change
+	}{
+		{
+			name: "same",
+			old:  map[string]string{"a": "b"},
+			new:  map[string]string{"a": "b"},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name: "changes",
+			old:  map[string]string{"a": "b", "c": "d", "e": "f"},
+			new:  map[string]string{"a": "b", "c": "x", "g": "h"},
+			res: []Change{
+				{Key: "c", Type: ChangeChanged, Old: "d", New: "x"},
+				{Key: "e", Type: ChangeRemoved, Old: "f"},
+				{Key: "g", Type: ChangeAdded, New: "h"},
+			},
+		},
+		{
+			name: "empty-value",
+			old:  map[string]string{},
+			new:  map[string]string{"a": ""},
+			res: []Change{
+				{Key: "a", Type: ChangeAdded},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res := DiffData(test.old, test.new)
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+			}
+		})
+	}
+}
+
+// TestFormatChanges tests the FormatChanges function
+func TestFormatChanges(t *testing.T) {
+	t.Parallel()
+	changes := []Change{
+		{Key: "c", Type: ChangeChanged, Old: "d", New: "x\n"},
+		{Key: "e", Type: ChangeRemoved, Old: "f"},
+		{Key: "g", Type: ChangeAdded, New: "h"},
+	}
+	tests := []struct {
+		name    string
+		changes []Change
+		reveal  bool
+		res     string
+	}{
+		{
+			name:    "masked",
+			changes: changes,
+			res: `~ c sha256:18ac3e7343f0 (1 bytes) -> sha256:73cb3858a687 (2 bytes)
+- e sha256:252f10c83610 (1 bytes)
++ g sha256:aaa9402664f1 (1 bytes)
+`,
+		},
+		{
+			name:    "revealed",
+			changes: changes,
+			reveal:  true,
+			res: `~ c "d" -> "x\n"
+- e "f"
++ g "h"
+`,
+		},
+		{
+			name:    "revealed-binary",
+			changes: []Change{{Key: "a", Type: ChangeChanged, Old: "\xff", New: "b"}},
+			reveal:  true,
+			res:     "~ a !!binary /w== -> \"b\"\n",
+		},
+		{
+			name: "no-changes",
+			res:  "",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res := FormatChanges(test.changes, test.reveal)
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
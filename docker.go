@@ -0,0 +1,104 @@
+package k8shhh
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultDockerServer is the server of the docker hub registry
+const DefaultDockerServer = "https://index.docker.io/v1/"
+
+// DockerConfigJSON is the content of a ~/.docker/config.json file, as stored
+// in a kubernetes.io/dockerconfigjson secret
+type DockerConfigJSON struct {
+	Auths map[string]DockerConfigEntry `json:"auths"`
+}
+
+// DockerConfigEntry is the credentials of a single docker registry
+type DockerConfigEntry struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Email         string `json:"email,omitempty"`
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	RegistryToken string `json:"registrytoken,omitempty"`
+}
+
+// DockerRegistryData returns the data of a kubernetes.io/dockerconfigjson
+// secret containing the credentials of the given registry
+func DockerRegistryData(server, username, password, email string) (map[string]string, error) {
+	if server == "" {
+		server = DefaultDockerServer
+	}
+	if username == "" || password == "" {
+		return nil, errors.New("username and password are required for a docker registry")
+	}
+	config := DockerConfigJSON{
+		Auths: map[string]DockerConfigEntry{
+			server: {
+				Username: username,
+				Password: password,
+				Email:    email,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{DockerConfigJSONKey: string(b)}, nil
+}
+
+// DockerConfigData returns the data of a kubernetes.io/dockerconfigjson
+// secret from the content of an existing ~/.docker/config.json file. Only the
+// auths are kept, as the credential helpers configured on the local machine
+// are not available to the cluster. The registries authenticated with an
+// identity or registry token only are kept as well.
+func DockerConfigData(input []byte) (map[string]string, error) {
+	var config DockerConfigJSON
+	if err := json.Unmarshal(input, &config); err != nil {
+		return nil, fmt.Errorf("parsing docker config: %v", err)
+	}
+	for server, entry := range config.Auths {
+		if entry.Auth == "" && (entry.Username == "" || entry.Password == "") && entry.IdentityToken == "" && entry.RegistryToken == "" {
+			delete(config.Auths, server)
+		}
+	}
+	if len(config.Auths) == 0 {
+		return nil, errors.New("no credentials found in docker config (credentials kept by a credential helper are not supported)")
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{DockerConfigJSONKey: string(b)}, nil
+}
+
+// formatDockerConfig pretty prints the content of a .dockerconfigjson key,
+// with the username and password of each registry broken out of its auth
+func formatDockerConfig(input string) ([]byte, error) {
+	var config DockerConfigJSON
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		return nil, fmt.Errorf("parsing docker config: %v", err)
+	}
+	for server, entry := range config.Auths {
+		if entry.Auth != "" && entry.Username == "" && entry.Password == "" {
+			b, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("decoding auth of %q: %v", server, err)
+			}
+			i := strings.Index(string(b), ":")
+			if i < 0 {
+				return nil, fmt.Errorf("decoding auth of %q: missing separator", server)
+			}
+			entry.Username = string(b[:i])
+			entry.Password = string(b[i+1:])
+			config.Auths[server] = entry
+		}
+	}
+	return json.MarshalIndent(config, "", "\t")
+}
@@ -0,0 +1,167 @@
+package k8shhh
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestDockerRegistryData tests the DockerRegistryData function
+func TestDockerRegistryData(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		server   string
+		username string
+		password string
+		email    string
+		res      map[string]string
+		err      error
+	}{
+		{
+			name:     "default-server",
+			username: "user",
+			password: "pass",
+			res:      map[string]string{".dockerconfigjson": `{"auths":{"https://index.docker.io/v1/":{"username":"user","password":"pass","auth":"dXNlcjpwYXNz"}}}`},
+		},
+		{
+			name:     "custom-server",
+			server:   "registry.example.com",
+			username: "user",
+			password: "pass",
+			email:    "user@example.com",
+			res:      map[string]string{".dockerconfigjson": `{"auths":{"registry.example.com":{"username":"user","password":"pass","email":"user@example.com","auth":"dXNlcjpwYXNz"}}}`},
+		},
+		{
+			name:     "missing-password",
+			username: "user",
+			err:      errors.New("username and password are required for a docker registry"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := DockerRegistryData(test.server, test.username, test.password, test.email)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestDockerConfigData tests the DockerConfigData function
+func TestDockerConfigData(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		res   map[string]string
+		err   error
+	}{
+		{
+			name:  "auths",
+			input: `{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"},"ghcr.io":{}},"credsStore":"desktop"}`,
+			res:   map[string]string{".dockerconfigjson": `{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"}}}`},
+		},
+		{
+			name:  "tokens",
+			input: `{"auths":{"registry.example.com":{"identitytoken":"id"},"ghcr.io":{"registrytoken":"reg"},"quay.io":{}}}`,
+			res:   map[string]string{".dockerconfigjson": `{"auths":{"ghcr.io":{"registrytoken":"reg"},"registry.example.com":{"identitytoken":"id"}}}`},
+		},
+		{
+			name:  "credential-helper",
+			input: `{"auths":{"ghcr.io":{}},"credsStore":"desktop"}`,
+			err:   errors.New("no credentials found in docker config (credentials kept by a credential helper are not supported)"),
+		},
+		{
+			name:  "invalid",
+			input: `{"auths":`,
+			err:   errors.New("parsing docker config: unexpected end of JSON input"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := DockerConfigData([]byte(test.input))
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestFormatDockerConfig tests the formatDockerConfig function
+func TestFormatDockerConfig(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		res   string
+		err   error
+	}{
+		{
+			name:  "auth",
+			input: `{"auths":{"registry.example.com":{"auth":"dXNlcjpwYXNz"}}}`,
+			res:   successFormatDockerConfigTest,
+		},
+		{
+			name:  "identity-token",
+			input: `{"auths":{"registry.example.com":{"username":"00000000-0000-0000-0000-000000000000","identitytoken":"id"}}}`,
+			res:   "{\n\t\"auths\": {\n\t\t\"registry.example.com\": {\n\t\t\t\"username\": \"00000000-0000-0000-0000-000000000000\",\n\t\t\t\"identitytoken\": \"id\"\n\t\t}\n\t}\n}",
+		},
+		{
+			name:  "invalid-auth",
+			input: `{"auths":{"registry.example.com":{"auth":"dXNlcg=="}}}`,
+			err:   errors.New(`decoding auth of "registry.example.com": missing separator`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := formatDockerConfig(test.input)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+const successFormatDockerConfigTest = `{
+	"auths": {
+		"registry.example.com": {
+			"username": "user",
+			"password": "pass",
+			"auth": "dXNlcjpwYXNz"
+		}
+	}
+}`
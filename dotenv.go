@@ -0,0 +1,153 @@
+package k8shhh
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// dotenvBinaryComment follows the base64 encoded form of the values which
+// are not valid UTF-8, as written by k8shhh to a dotenv file. Only the values
+// marked this way are base64 decoded, so that the values of other dotenv files
+// are never reinterpreted.
+const dotenvBinaryComment = "# k8shhh:binary"
+
+// dotenvBareValueRegexp matches the values which are written unquoted to a
+// dotenv file, as they are read back as is
+var dotenvBareValueRegexp = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=~^-]*$`)
+
+// dotenvVariableRegexp matches a variable reference such as $NAME or ${NAME}
+var dotenvVariableRegexp = regexp.MustCompile(`^\$\{?([A-Z0-9_]+)\}?`)
+
+// parseDotenv parses the dotenv formatted input. Each line holds either a
+// KEY=value or a yaml style KEY: value assignment, optionally prefixed by
+// export, and blank lines and lines starting with # are ignored.
+//
+// An unquoted value ends at a # comment and is trimmed. A single quoted value
+// is taken as is. A double quoted value may hold \n and \r for a newline and
+// a carriage return, and a backslash before any other character stands for
+// that character. Unquoted and double quoted values expand the $NAME and
+// ${NAME} references to the keys assigned above, unless the $ is escaped.
+// An unquoted value followed by the "# k8shhh:binary" comment is base64
+// decoded.
+func parseDotenv(input io.Reader) (map[string]string, error) {
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.Trim(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "export ") || strings.HasPrefix(trimmed, "export\t") {
+			trimmed = strings.TrimLeft(trimmed[len("export"):], " \t")
+		}
+
+		i := strings.IndexAny(trimmed, "=:")
+		if i < 0 {
+			return nil, errors.New("Can't separate key from value")
+		}
+		key := strings.Trim(trimmed[:i], " \t")
+		value, err := parseDotenvValue(strings.TrimLeft(trimmed[i+1:], " \t"), env)
+		if err != nil {
+			return nil, fmt.Errorf("value of key %q is marked as binary, but is not valid base64: %v", key, err)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// parseDotenvValue parses the value of an assignment, which may be followed
+// by a comment. A quoted value missing its closing quote is read unquoted.
+// An error is only returned for a binary value which is not valid base64.
+func parseDotenvValue(value string, env map[string]string) (string, error) {
+	if len(value) > 1 && (value[0] == '"' || value[0] == '\'') {
+		quote := value[0]
+		for i := 1; i < len(value); i++ {
+			if quote == '"' && value[i] == '\\' {
+				i++
+				continue
+			}
+			if value[i] != quote {
+				continue
+			}
+			// a quote is only closing when the rest of the line is a comment,
+			// so that a value such as 'it's' is read whole
+			rest := strings.TrimLeft(value[i+1:], " \t")
+			if rest != "" && rest[0] != '#' {
+				continue
+			}
+			if quote == '\'' {
+				return value[1:i], nil
+			}
+			return unescapeDotenv(value[1:i], true, env), nil
+		}
+	}
+	var comment string
+	if i := strings.Index(value, "#"); i >= 0 {
+		value, comment = value[:i], strings.TrimRight(value[i:], " \t")
+	}
+	value = strings.TrimRight(value, " \t")
+	if comment == dotenvBinaryComment {
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return unescapeDotenv(value, false, env), nil
+}
+
+// unescapeDotenv expands the variable references of the value. The escape
+// sequences of double quoted values are replaced when quoted is set, while
+// an unquoted value only has its \$ escapes replaced.
+func unescapeDotenv(value string, quoted bool, env map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '\\' && i+1 < len(value) && (quoted || value[i+1] == '$'):
+			i++
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(value[i])
+			}
+		case c == '$':
+			m := dotenvVariableRegexp.FindStringSubmatch(value[i:])
+			if m == nil {
+				b.WriteByte(c)
+				continue
+			}
+			b.WriteString(env[m[1]])
+			i += len(m[0]) - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// quoteDotenvValue returns the value as written to a dotenv file, so that
+// parseDotenv reads it back unchanged. Values holding any character other
+// than a few safe ones are double quoted and escaped, and values which are
+// not valid UTF-8 are base64 encoded and marked by the binary comment.
+func quoteDotenvValue(v string) string {
+	if !utf8.ValidString(v) {
+		return base64.StdEncoding.EncodeToString([]byte(v)) + " " + dotenvBinaryComment
+	}
+	if dotenvBareValueRegexp.MatchString(v) {
+		return v
+	}
+	return `"` + doubleQuoteEscape(v) + `"`
+}
@@ -0,0 +1,23 @@
+//go:build go1.18
+// +build go1.18
+
+package k8shhh
+
+import (
+	"testing"
+)
+
+// FuzzRoundTrip tests that the output of Decode is encoded back by Encode to
+// the same data for arbitrary keys and values
+func FuzzRoundTrip(f *testing.F) {
+	for _, v := range roundTripValues {
+		f.Add("key", []byte(v))
+	}
+	f.Add("export.key", []byte("a"))
+	f.Fuzz(func(t *testing.T, key string, value []byte) {
+		if validateSecretKey(key) != nil {
+			t.Skip()
+		}
+		assertRoundTrip(t, map[string]string{key: string(value)})
+	})
+}
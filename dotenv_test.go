@@ -0,0 +1,187 @@
+package k8shhh
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripValues are values which a naive dotenv format does not read back
+// unchanged
+var roundTripValues = []string{
+	"",
+	"plain",
+	" padded ",
+	"a # not a comment",
+	"#",
+	`"quoted"`,
+	`'single'`,
+	`it's "$HOME"`,
+	`a"#"b`,
+	`a'#'b`,
+	`\`,
+	`\n`,
+	`\$HOME`,
+	"${HOME}",
+	"$",
+	"multi\nline\r\n",
+	"\ttab",
+	"`cmd`!",
+	"\x00\xff\xfe binary",
+	"!!binary /w8A",
+	"/w8A # k8shhh:binary",
+	"-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+	strings.Repeat("x", 100000),
+}
+
+// TestParseDotenv tests the parseDotenv function
+func TestParseDotenv(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		res   map[string]string
+		err   error
+	}{
+		{
+			name:  "empty",
+			input: "",
+			res:   map[string]string{},
+		},
+		{
+			name:  "comments",
+			input: "# comment\n\n  # indented comment\nA=b # trailing comment\nB=c#d\n",
+			res:   map[string]string{"A": "b", "B": "c"},
+		},
+		{
+			name:  "export",
+			input: "export A=b\nexport\tB=c\nexporter=d\n",
+			res:   map[string]string{"A": "b", "B": "c", "exporter": "d"},
+		},
+		{
+			name:  "yaml-style",
+			input: "A: b\nB=c: d\n",
+			res:   map[string]string{"A": "b", "B": "c: d"},
+		},
+		{
+			name:  "trimmed",
+			input: "  A = b  \r\nB=\n",
+			res:   map[string]string{"A": "b", "B": ""},
+		},
+		{
+			name:  "single-quoted",
+			input: `A='b # c $HOME \n' # comment` + "\nB='it's'\n",
+			res:   map[string]string{"A": `b # c $HOME \n`, "B": "it's"},
+		},
+		{
+			name:  "double-quoted",
+			input: `A=" b\n\r\"\\\$c\d " # comment` + "\n" + `B="a"b"` + "\n",
+			res:   map[string]string{"A": " b\n\r\"\\$cd ", "B": `a"b`},
+		},
+		{
+			name:  "unterminated-quote",
+			input: `A="b` + "\nB='c\n",
+			res:   map[string]string{"A": `"b`, "B": "'c"},
+		},
+		{
+			name:  "variables",
+			input: "A=b\nB=$A-${A}-$C-$a\nC=\"$A\\$A\"\nD='$A'\nE=\\$A\\n\n",
+			res:   map[string]string{"A": "b", "B": "b-b--$a", "C": "b$A", "D": "$A", "E": `$A\n`},
+		},
+		{
+			name:  "binary",
+			input: "A=/w8A # k8shhh:binary\nB=\"/w8A\" # k8shhh:binary\nC=/w8A # comment\nD=!!binary /w8A\n",
+			res:   map[string]string{"A": "\xff\x0f\x00", "B": "/w8A", "C": "/w8A", "D": "!!binary /w8A"},
+		},
+		{
+			name:  "binary-invalid",
+			input: "A=/w8 # k8shhh:binary\n",
+			err:   errors.New(`value of key "A" is marked as binary, but is not valid base64: illegal base64 data at input byte 0`),
+		},
+		{
+			name:  "no-separator",
+			input: "A=b\n-1\n",
+			err:   errors.New("Can't separate key from value"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := parseDotenv(strings.NewReader(test.input))
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %q but got %q", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestQuoteDotenvValue tests the quoteDotenvValue function
+func TestQuoteDotenvValue(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		value string
+		res   string
+	}{
+		{value: "", res: ""},
+		{value: "postgres://db:5432/app?a=b", res: `"postgres://db:5432/app?a=b"`},
+		{value: "user@example.com", res: "user@example.com"},
+		{value: "a b", res: `"a b"`},
+		{value: `it's "$HOME"`, res: `"it's \"\$HOME\""`},
+		{value: "a\nb", res: `"a\nb"`},
+		{value: "\xff\x0f\x00", res: "/w8A # k8shhh:binary"},
+		{value: "/w8A # k8shhh:binary", res: `"/w8A # k8shhh:binary"`},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.value, func(t *testing.T) {
+			if res := quoteDotenvValue(test.value); res != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestRoundTrip tests that the output of Decode is encoded back by Encode to
+// the same data
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	data := make(map[string]string, len(roundTripValues))
+	for i, v := range roundTripValues {
+		data[strings.Repeat("k", i+1)] = v
+	}
+	assertRoundTrip(t, data)
+}
+
+// assertRoundTrip encodes the data to a secret, and checks that encoding the
+// decoded secret gives the data back
+func assertRoundTrip(t *testing.T, data map[string]string) {
+	t.Helper()
+	encoded, err := EncodeYAML(Secret{Name: "round-trip", Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(bytes.NewReader(encoded), DecodeYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ParseSecret(bytes.NewReader(decoded), Secret{Name: "round-trip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Data, data) {
+		t.Fatalf("expected data to be %q but got %q from %q", data, res.Data, decoded)
+	}
+}
@@ -1,62 +1,275 @@
 package k8shhh
 
 import (
-	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
+	"unicode"
+	"unicode/utf8"
 
-	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v2"
 )
 
-// Secret is the type containing the name and the underlying data
+// Secret is the type containing the metadata, the type and the underlying
+// data. SecretObject is the shape of the objects it is encoded to. When
+// HashSuffix is set, the secret is encoded immutable with the hash of its
+// content appended to its name, as the secretGenerator of kustomize does.
 type Secret struct {
-	Name string
-	Data map[string]string
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+	Type        SecretType
+	Immutable   bool
+	HashSuffix  bool
+	Data        map[string]string
 }
 
 // Encoder is a type for function that encodes the given Secret
 type Encoder func(Secret) ([]byte, error)
 
-// template is the template struct for both json and yaml encoding
+// ListEncoder is a type for function that encodes several Secrets at once
+type ListEncoder func([]Secret) ([]byte, error)
+
+// DataMode determines where the values of a secret are written to in the
+// generated manifest
+type DataMode string
+
+const (
+	// DataModeData writes every value base64 encoded under data (default)
+	DataModeData DataMode = "data"
+	// DataModeStringData writes every value as is under stringData, which
+	// requires the values to be valid UTF-8
+	DataModeStringData DataMode = "stringData"
+	// DataModeHybrid writes printable UTF-8 values under stringData and the
+	// others base64 encoded under data
+	DataModeHybrid DataMode = "hybrid"
+)
+
+// template is the template struct for both json and yaml encoding. Data is
+// only nil when the values are written to stringData, so that it is omitted
+// rather than written empty.
 type template struct {
-	APIVersion string            `json:"apiVersion" yaml:"apiVersion"`
-	Data       map[string]string `json:"data" yaml:"data"`
-	Kind       string            `json:"kind" yaml:"kind"`
-	Metadata   map[string]string `json:"metadata" yaml:"metadata"`
-	Type       string            `json:"type" yaml:"type"`
+	APIVersion string             `json:"apiVersion" yaml:"apiVersion"`
+	Data       *map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+	Immutable  *bool              `json:"immutable,omitempty" yaml:"immutable,omitempty"`
+	Kind       string             `json:"kind" yaml:"kind"`
+	Metadata   ObjectMeta         `json:"metadata" yaml:"metadata"`
+	StringData map[string]string  `json:"stringData,omitempty" yaml:"stringData,omitempty"`
+	Type       string             `json:"type" yaml:"type"`
+}
+
+// listTemplate is the template struct of a v1 List holding several secrets
+type listTemplate struct {
+	APIVersion string        `json:"apiVersion" yaml:"apiVersion"`
+	Items      []interface{} `json:"items" yaml:"items"`
+	Kind       string        `json:"kind" yaml:"kind"`
 }
 
 // Encode encodes the input based on the given encoder
 func Encode(input io.Reader, encoder Encoder, name string) ([]byte, error) {
-	data, err := godotenv.Parse(input)
+	return EncodeSecret(input, encoder, Secret{Name: name})
+}
+
+// EncodeSecret encodes the input into the given secret based on the given
+// encoder. Keys parsed from the input are added to the data of the secret,
+// which must not already contain them.
+func EncodeSecret(input io.Reader, encoder Encoder, secret Secret) ([]byte, error) {
+	secret, err := ParseSecret(input, secret)
 	if err != nil {
 		return nil, err
 	}
-	return encoder(Secret{name, data})
+	return encoder(secret)
+}
+
+// ParseSecret parses the dotenv formatted input into the data of the given
+// secret, which must not already contain the keys parsed. The invalid keys
+// of the input are all reported in ValidationErrors.
+func ParseSecret(input io.Reader, secret Secret) (Secret, error) {
+	data, err := parseDotenv(input)
+	if err != nil {
+		return Secret{}, err
+	}
+	if errs := validateDataKeys(data); errs != nil {
+		return Secret{}, errs
+	}
+	secret.Data, err = MergeData(secret.Data, data)
+	if err != nil {
+		return Secret{}, err
+	}
+	return secret, nil
 }
 
 // EncodeJSON encodes the secret and output it to a json format
 func EncodeJSON(secret Secret) ([]byte, error) {
-	return json.MarshalIndent(generateTemplate(secret), "", "\t")
+	return JSONEncoder(DataModeData)(secret)
 }
 
 // EncodeYAML encodes the secret and output it to a yaml format
 func EncodeYAML(secret Secret) ([]byte, error) {
-	return yaml.Marshal(generateTemplate(secret))
+	return YAMLEncoder(DataModeData)(secret)
+}
+
+// EncodeJSONList encodes the secrets and output them to a json format, as
+// the items of a v1 List
+func EncodeJSONList(secrets []Secret) ([]byte, error) {
+	return JSONListEncoder(DataModeData)(secrets)
+}
+
+// EncodeYAMLList encodes the secrets and output them to a yaml format, as a
+// stream of documents separated by "---"
+func EncodeYAMLList(secrets []Secret) ([]byte, error) {
+	return YAMLListEncoder(DataModeData)(secrets)
+}
+
+// JSONEncoder returns an encoder which outputs the secret to a json format,
+// with its values written according to the given mode
+func JSONEncoder(mode DataMode) Encoder {
+	return jsonEncoder(dataTemplater(mode))
+}
+
+// YAMLEncoder returns an encoder which outputs the secret to a yaml format,
+// with its values written according to the given mode
+func YAMLEncoder(mode DataMode) Encoder {
+	return yamlEncoder(dataTemplater(mode))
+}
+
+// JSONListEncoder returns a list encoder which outputs the secrets to a json
+// format as the items of a v1 List, with their values written according to
+// the given mode
+func JSONListEncoder(mode DataMode) ListEncoder {
+	return jsonListEncoder(dataTemplater(mode))
+}
+
+// YAMLListEncoder returns a list encoder which outputs the secrets to a yaml
+// format as a stream of documents separated by "---", with their values
+// written according to the given mode
+func YAMLListEncoder(mode DataMode) ListEncoder {
+	return yamlListEncoder(dataTemplater(mode))
+}
+
+// templater is a type for function that puts the given Secret to the
+// kubernetes object to be encoded
+type templater func(Secret) (interface{}, error)
+
+// dataTemplater returns a templater which puts the secret to a Secret
+// object, with its values written according to the given mode
+func dataTemplater(mode DataMode) templater {
+	return func(secret Secret) (interface{}, error) {
+		return generateTemplate(secret, mode)
+	}
+}
+
+// jsonEncoder returns an encoder which outputs the object of the secret
+// to a json format
+func jsonEncoder(tmpl templater) Encoder {
+	return func(secret Secret) ([]byte, error) {
+		obj, err := tmpl(secret)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(obj, "", "\t")
+	}
+}
+
+// yamlEncoder returns an encoder which outputs the object of the secret
+// to a yaml format
+func yamlEncoder(tmpl templater) Encoder {
+	return func(secret Secret) ([]byte, error) {
+		obj, err := tmpl(secret)
+		if err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(obj)
+	}
+}
+
+// jsonListEncoder returns a list encoder which outputs the objects of the
+// secrets to a json format, as the items of a v1 List
+func jsonListEncoder(tmpl templater) ListEncoder {
+	return func(secrets []Secret) ([]byte, error) {
+		objs, err := generateTemplates(secrets, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(listTemplate{APIVersion: "v1", Items: objs, Kind: "List"}, "", "\t")
+	}
+}
+
+// yamlListEncoder returns a list encoder which outputs the objects of the
+// secrets to a yaml format, as a stream of documents separated by "---"
+func yamlListEncoder(tmpl templater) ListEncoder {
+	return func(secrets []Secret) ([]byte, error) {
+		objs, err := generateTemplates(secrets, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		var out []byte
+		for i, obj := range objs {
+			b, err := yaml.Marshal(obj)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				out = append(out, "---\n"...)
+			}
+			out = append(out, b...)
+		}
+		return out, nil
+	}
+}
+
+// generateTemplates puts each of the secrets to its kubernetes object with
+// the given templater, after checking that no two secrets share the same
+// name and namespace
+func generateTemplates(secrets []Secret, tmpl templater) ([]interface{}, error) {
+	objs := make([]interface{}, 0, len(secrets))
+	seen := make(map[string]bool)
+	for _, secret := range secrets {
+		if seen[qualifiedSecretName(secret)] {
+			return nil, fmt.Errorf("duplicate secret %q", qualifiedSecretName(secret))
+		}
+		seen[qualifiedSecretName(secret)] = true
+		obj, err := tmpl(secret)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %v", secret.Name, err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// generateTemplate puts the Secret metadata, type and data to the kubernetes
+// template, after checking that the metadata and the data are valid. The
+// values are written to data or stringData according to the given mode.
+func generateTemplate(secret Secret, mode DataMode) (template, error) {
+	if secret.Type == "" {
+		secret.Type = SecretTypeOpaque
+	}
+	secret = withHashSuffix(secret)
+	if err := ValidateSecret(secret); err != nil {
+		return template{}, err
+	}
+	if err := validateType(secret); err != nil {
+		return template{}, err
+	}
+	obj, err := NewSecretObject(secret, mode)
+	if err != nil {
+		return template{}, err
+	}
+	return obj.template(), nil
 }
 
-// generateTemplate puts the Secret name and data to the kubernetes template
-func generateTemplate(secret Secret) template {
-	tmpl := template{
-		APIVersion: "v1",
-		Data:       make(map[string]string),
-		Kind:       "Secret",
-		Metadata:   map[string]string{"name": secret.Name},
-		Type:       "Opaque",
+// isPrintable checks whether the value is valid UTF-8 made of printable
+// characters and whitespace only, and can thus be reviewed as is
+func isPrintable(value string) bool {
+	if !utf8.ValidString(value) {
+		return false
 	}
-	for k, v := range secret.Data {
-		tmpl.Data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	for _, r := range value {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
 	}
-	return tmpl
+	return true
 }
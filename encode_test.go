@@ -3,6 +3,7 @@ package k8shhh
 import (
 	"errors"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -23,6 +24,18 @@ func TestEncode(t *testing.T) {
 			name:    "error-test",
 			err:     errors.New("Can't separate key from value"),
 		},
+		{
+			input:   strings.NewReader("a b=c\nd@e=f\ng=h"),
+			encoder: EncodeYAML,
+			name:    "invalid-keys",
+			err:     errors.New(`invalid key "a b": must consist of alphanumeric characters, '-', '_' or '.'; invalid key "d@e": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+		{
+			input:   strings.NewReader("a=b"),
+			encoder: EncodeYAML,
+			name:    "Invalid_Name",
+			err:     errors.New(`invalid name "Invalid_Name": must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character`),
+		},
 		{
 			input:   strings.NewReader(""),
 			encoder: EncodeJSON,
@@ -69,6 +82,236 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+// TestEncodeSecret tests the EncodeSecret function
+func TestEncodeSecret(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input  io.Reader
+		secret Secret
+		res    string
+		err    error
+	}{
+		{
+			input:  strings.NewReader(""),
+			secret: Secret{Name: "yaml-one", Data: map[string]string{"a": "b"}},
+			res:    successEncodeYAMLTestOne,
+		},
+		{
+			input:  strings.NewReader("a=c"),
+			secret: Secret{Name: "yaml-duplicate", Data: map[string]string{"a": "b"}},
+			err:    errors.New(`duplicate key "a"`),
+		},
+		{
+			input:  strings.NewReader("a=b"),
+			secret: Secret{Name: "yaml-immutable", Immutable: true},
+			res:    successEncodeYAMLTestImmutable,
+		},
+		{
+			input:  strings.NewReader("a=b"),
+			secret: Secret{Name: "yaml-hashed", HashSuffix: true},
+			res:    successEncodeYAMLTestHashSuffix,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.secret.Name, func(t *testing.T) {
+			res, err := EncodeSecret(test.input, EncodeYAML, test.secret)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestParseSecret tests the ParseSecret function
+func TestParseSecret(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input  io.Reader
+		secret Secret
+		res    Secret
+		err    error
+	}{
+		{
+			input:  strings.NewReader("a=b"),
+			secret: Secret{Name: "parse-one", Data: map[string]string{"c": "d"}},
+			res:    Secret{Name: "parse-one", Data: map[string]string{"a": "b", "c": "d"}},
+		},
+		{
+			input:  strings.NewReader("a=c"),
+			secret: Secret{Name: "parse-duplicate", Data: map[string]string{"a": "b"}},
+			err:    errors.New(`duplicate key "a"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.secret.Name, func(t *testing.T) {
+			res, err := ParseSecret(test.input, test.secret)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+			}
+		})
+	}
+}
+
+// TestEncodeList tests the EncodeJSONList and EncodeYAMLList functions
+func TestEncodeList(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		encoder ListEncoder
+		secrets []Secret
+		res     string
+		err     error
+	}{
+		{
+			name:    "json-list",
+			encoder: EncodeJSONList,
+			secrets: []Secret{
+				{Name: "json-one", Data: map[string]string{"a": "b"}},
+				{Name: "json-empty", Data: make(map[string]string)},
+			},
+			res: successEncodeJSONListTest,
+		},
+		{
+			name:    "json-list-empty",
+			encoder: EncodeJSONList,
+			res:     "{\n\t\"apiVersion\": \"v1\",\n\t\"items\": [],\n\t\"kind\": \"List\"\n}",
+		},
+		{
+			name:    "yaml-list",
+			encoder: EncodeYAMLList,
+			secrets: []Secret{
+				{Name: "yaml-one", Data: map[string]string{"a": "b"}},
+				{Name: "yaml-empty", Data: make(map[string]string)},
+			},
+			res: successEncodeYAMLTestOne + "---\n" + successEncodeYAMLTestEmpty,
+		},
+		{
+			name:    "yaml-list-duplicate",
+			encoder: EncodeYAMLList,
+			secrets: []Secret{
+				{Name: "yaml-one", Namespace: "production"},
+				{Name: "yaml-one", Namespace: "production"},
+			},
+			err: errors.New(`duplicate secret "production/yaml-one"`),
+		},
+		{
+			name:    "yaml-list-invalid",
+			encoder: EncodeYAMLList,
+			secrets: []Secret{
+				{Name: "yaml-tls", Type: SecretTypeTLS},
+			},
+			err: errors.New(`secret "yaml-tls": secret of type "kubernetes.io/tls" requires key "tls.crt"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.encoder(test.secrets)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestDataMode tests the encoders returned for each data mode
+func TestDataMode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		encoder Encoder
+		data    map[string]string
+		res     string
+		err     error
+	}{
+		{
+			name:    "yaml-data",
+			encoder: YAMLEncoder(DataModeData),
+			data:    map[string]string{"a": "b"},
+			res:     "apiVersion: v1\ndata:\n  a: Yg==\nkind: Secret\nmetadata:\n  name: yaml-data\ntype: Opaque\n",
+		},
+		{
+			name:    "yaml-string-data",
+			encoder: YAMLEncoder(DataModeStringData),
+			data:    map[string]string{"a": "b", "c": "line\n\x01"},
+			res:     successEncodeYAMLTestStringData,
+		},
+		{
+			name:    "yaml-string-data-empty",
+			encoder: YAMLEncoder(DataModeStringData),
+			res:     "apiVersion: v1\nkind: Secret\nmetadata:\n  name: yaml-string-data-empty\ntype: Opaque\n",
+		},
+		{
+			name:    "yaml-string-data-binary",
+			encoder: YAMLEncoder(DataModeStringData),
+			data:    map[string]string{"a": "b", "c": "\xff\xfe"},
+			err:     errors.New(`value of key "c" is not valid UTF-8 and cannot be written to stringData`),
+		},
+		{
+			name:    "json-hybrid",
+			encoder: JSONEncoder(DataModeHybrid),
+			data:    map[string]string{"a": "b", "c": "\xff\xfe", "d": "\x01"},
+			res:     successEncodeJSONTestHybrid,
+		},
+		{
+			name:    "json-unknown",
+			encoder: JSONEncoder("base32"),
+			err:     errors.New(`unknown data mode "base32"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.encoder(Secret{Name: test.name, Data: test.data})
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
 // TestEncodeJSON tests the EncodeJSON function
 func TestEncodeJSON(t *testing.T) {
 	t.Parallel()
@@ -78,13 +321,27 @@ func TestEncodeJSON(t *testing.T) {
 		err    error
 	}{
 		{
-			secret: Secret{"json-empty", make(map[string]string)},
+			secret: Secret{Name: "json-empty", Data: make(map[string]string)},
 			res:    successEncodeJSONTestEmpty,
 		},
 		{
-			secret: Secret{"json-one", map[string]string{"a": "b"}},
+			secret: Secret{Name: "json-one", Data: map[string]string{"a": "b"}},
 			res:    successEncodeJSONTestOne,
 		},
+		{
+			secret: Secret{
+				Name:        "json-metadata",
+				Namespace:   "production",
+				Labels:      map[string]string{"app": "web"},
+				Annotations: map[string]string{"example.com/owner": "team-a"},
+				Data:        map[string]string{"a": "b"},
+			},
+			res: successEncodeJSONTestMetadata,
+		},
+		{
+			secret: Secret{Name: "json-label-error", Labels: map[string]string{"app": "web server"}},
+			err:    errors.New(`invalid label value "web server": must be empty or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character`),
+		},
 	}
 
 	for _, test := range tests {
@@ -116,13 +373,21 @@ func TestEncodeYAML(t *testing.T) {
 		err    error
 	}{
 		{
-			secret: Secret{"yaml-empty", make(map[string]string)},
+			secret: Secret{Name: "yaml-empty", Data: make(map[string]string)},
 			res:    successEncodeYAMLTestEmpty,
 		},
 		{
-			secret: Secret{"yaml-one", map[string]string{"a": "b"}},
+			secret: Secret{Name: "yaml-one", Data: map[string]string{"a": "b"}},
 			res:    successEncodeYAMLTestOne,
 		},
+		{
+			secret: Secret{Name: "yaml-basic-auth", Type: SecretTypeBasicAuth, Data: map[string]string{"username": "admin"}},
+			res:    successEncodeYAMLTestBasicAuth,
+		},
+		{
+			secret: Secret{Name: "yaml-tls-error", Type: SecretTypeTLS, Data: map[string]string{"tls.crt": "cert"}},
+			err:    errors.New(`secret of type "kubernetes.io/tls" requires key "tls.key"`),
+		},
 	}
 
 	for _, test := range tests {
@@ -168,6 +433,78 @@ const (
 	"type": "Opaque"
 }`
 
+	successEncodeJSONTestMetadata = `{
+	"apiVersion": "v1",
+	"data": {
+		"a": "Yg=="
+	},
+	"kind": "Secret",
+	"metadata": {
+		"name": "json-metadata",
+		"namespace": "production",
+		"labels": {
+			"app": "web"
+		},
+		"annotations": {
+			"example.com/owner": "team-a"
+		}
+	},
+	"type": "Opaque"
+}`
+
+	successEncodeJSONListTest = `{
+	"apiVersion": "v1",
+	"items": [
+		{
+			"apiVersion": "v1",
+			"data": {
+				"a": "Yg=="
+			},
+			"kind": "Secret",
+			"metadata": {
+				"name": "json-one"
+			},
+			"type": "Opaque"
+		},
+		{
+			"apiVersion": "v1",
+			"data": {},
+			"kind": "Secret",
+			"metadata": {
+				"name": "json-empty"
+			},
+			"type": "Opaque"
+		}
+	],
+	"kind": "List"
+}`
+
+	successEncodeJSONTestHybrid = `{
+	"apiVersion": "v1",
+	"data": {
+		"c": "//4=",
+		"d": "AQ=="
+	},
+	"kind": "Secret",
+	"metadata": {
+		"name": "json-hybrid"
+	},
+	"stringData": {
+		"a": "b"
+	},
+	"type": "Opaque"
+}`
+
+	successEncodeYAMLTestStringData = `apiVersion: v1
+kind: Secret
+metadata:
+  name: yaml-string-data
+stringData:
+  a: b
+  c: "line\n\x01"
+type: Opaque
+`
+
 	successEncodeYAMLTestEmpty = `apiVersion: v1
 data: {}
 kind: Secret
@@ -183,5 +520,34 @@ kind: Secret
 metadata:
   name: yaml-one
 type: Opaque
+`
+
+	successEncodeYAMLTestImmutable = `apiVersion: v1
+data:
+  a: Yg==
+immutable: true
+kind: Secret
+metadata:
+  name: yaml-immutable
+type: Opaque
+`
+
+	successEncodeYAMLTestHashSuffix = `apiVersion: v1
+data:
+  a: Yg==
+immutable: true
+kind: Secret
+metadata:
+  name: yaml-hashed-mh777g8986
+type: Opaque
+`
+
+	successEncodeYAMLTestBasicAuth = `apiVersion: v1
+data:
+  username: YWRtaW4=
+kind: Secret
+metadata:
+  name: yaml-basic-auth
+type: kubernetes.io/basic-auth
 `
 )
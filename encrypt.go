@@ -0,0 +1,214 @@
+package k8shhh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"golang.org/x/crypto/openpgp"
+	pgparmor "golang.org/x/crypto/openpgp/armor"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+	// keys which declare no preferred hash are signed with RIPEMD-160, as
+	// mandated by OpenPGP
+	_ "golang.org/x/crypto/ripemd160"
+)
+
+const (
+	// ageHeader starts the binary format of an age encrypted file
+	ageHeader = "age-encryption.org/"
+	// pgpMessageType is the armor type of an OpenPGP encrypted message
+	pgpMessageType = "PGP MESSAGE"
+)
+
+// Encrypter is a type for function that encrypts the given plaintext
+type Encrypter func([]byte) ([]byte, error)
+
+// DecryptionKeys are the private keys used to decrypt encrypted inputs
+type DecryptionKeys struct {
+	// AgeIdentities is the content of age identity files, holding one
+	// AGE-SECRET-KEY-1 identity per line
+	AgeIdentities []byte
+	// PGPKeyring is the armored or binary OpenPGP keyring holding the
+	// private keys, which must not be protected by a passphrase
+	PGPKeyring []byte
+}
+
+// AgeEncrypter returns an encrypter which encrypts to the given age X25519
+// recipients, of the form age1..., and armors the output
+func AgeEncrypter(recipients ...string) (Encrypter, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no age recipient given")
+	}
+	parsed := make([]age.Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %v", r, err)
+		}
+		parsed = append(parsed, recipient)
+	}
+	return func(plaintext []byte) ([]byte, error) {
+		var buf bytes.Buffer
+		a := agearmor.NewWriter(&buf)
+		w, err := age.Encrypt(a, parsed...)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(plaintext); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		if err := a.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	}, nil
+}
+
+// PGPEncrypter returns an encrypter which encrypts to the public keys of the
+// given armored or binary OpenPGP keyring, and armors the output
+func PGPEncrypter(keyring []byte) (Encrypter, error) {
+	entities, err := readKeyring(keyring)
+	if err != nil {
+		return nil, err
+	}
+	return func(plaintext []byte) ([]byte, error) {
+		return pgpEncrypt(entities, plaintext)
+	}, nil
+}
+
+// pgpEncrypt encrypts the plaintext to the given OpenPGP entities, and armors
+// the output
+func pgpEncrypt(entities openpgp.EntityList, plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	a, err := pgparmor.Encode(&buf, pgpMessageType, nil)
+	if err != nil {
+		return nil, err
+	}
+	w, err := openpgp.Encrypt(a, entities, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := a.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// DecryptInput returns the content of the input, decrypted with the given
+// keys if it is an age encrypted file, armored or not, an armored OpenPGP
+// message or a SOPS encrypted yaml document. Any other input is returned as
+// is.
+func DecryptInput(input io.Reader, keys DecryptionKeys) (io.Reader, error) {
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(b)
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte(agearmor.Header)):
+		return decryptAge(agearmor.NewReader(bytes.NewReader(trimmed)), keys)
+	case bytes.HasPrefix(b, []byte(ageHeader)):
+		return decryptAge(bytes.NewReader(b), keys)
+	case bytes.HasPrefix(trimmed, []byte("-----BEGIN "+pgpMessageType+"-----")):
+		return decryptPGP(trimmed, keys)
+	case isSOPS(b):
+		out, err := DecryptSOPS(b, keys)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(out), nil
+	}
+	return bytes.NewReader(b), nil
+}
+
+// decryptAge decrypts the age encrypted input with the age identities of
+// the keys
+func decryptAge(input io.Reader, keys DecryptionKeys) (io.Reader, error) {
+	if len(keys.AgeIdentities) == 0 {
+		return nil, errors.New("input is encrypted with age, but no age identity is given")
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(keys.AgeIdentities))
+	if err != nil {
+		return nil, fmt.Errorf("parsing age identities: %v", err)
+	}
+	r, err := age.Decrypt(input, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting age input: %v", err)
+	}
+	// the whole input is read so that a corrupted input is reported here,
+	// rather than by the parser reading it
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting age input: %v", err)
+	}
+	return bytes.NewReader(b), nil
+}
+
+// decryptPGP decrypts the armored OpenPGP message with the keyring of the
+// keys. The signature of a signed message is checked if the keyring holds the
+// key of its signer, and the message is rejected if it does not match.
+func decryptPGP(input []byte, keys DecryptionKeys) (io.Reader, error) {
+	if len(keys.PGPKeyring) == 0 {
+		return nil, errors.New("input is encrypted with OpenPGP, but no OpenPGP keyring is given")
+	}
+	entities, err := readKeyring(keys.PGPKeyring)
+	if err != nil {
+		return nil, err
+	}
+	block, err := pgparmor.Decode(bytes.NewReader(input))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting OpenPGP input: %v", err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, entities, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting OpenPGP input: %v", err)
+	}
+	// the signature of the message, if any, is only checked once the body
+	// is read in full
+	b, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting OpenPGP input: %v", err)
+	}
+	if md.IsSigned && md.SignatureError != nil {
+		return nil, fmt.Errorf("verifying OpenPGP signature: %v", md.SignatureError)
+	}
+	return bytes.NewReader(b), nil
+}
+
+// readKeyring reads the armored or binary OpenPGP keyring. Only RSA, DSA and
+// ElGamal keys are supported, ECC keys such as ed25519 or cv25519 are not.
+func readKeyring(keyring []byte) (openpgp.EntityList, error) {
+	var entities openpgp.EntityList
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(keyring), []byte("-----BEGIN ")) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(keyring))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(keyring))
+	}
+	if _, ok := err.(pgperrors.UnsupportedError); ok {
+		return nil, fmt.Errorf("reading OpenPGP keyring: %v (only RSA, DSA and ElGamal keys are supported, not ECC keys such as ed25519 or cv25519)", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading OpenPGP keyring: %v", err)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("reading OpenPGP keyring: no key found")
+	}
+	return entities, nil
+}
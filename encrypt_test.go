@@ -0,0 +1,253 @@
+package k8shhh
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/openpgp"
+	pgparmor "golang.org/x/crypto/openpgp/armor"
+)
+
+// TestEncrypters tests the AgeEncrypter and PGPEncrypter functions along
+// with DecryptInput
+func TestEncrypters(t *testing.T) {
+	t.Parallel()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity, err := openpgp.NewEntity("k8shhh", "", "k8shhh@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var publicKeyring, privateKeyring bytes.Buffer
+	if err := entity.Serialize(&publicKeyring); err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(&privateKeyring, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ageEncrypter, err := AgeEncrypter(identity.Recipient().String(), other.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgpEncrypter, err := PGPEncrypter(publicKeyring.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		encrypt Encrypter
+		header  string
+		keys    DecryptionKeys
+		err     error
+	}{
+		{
+			name:    "age",
+			encrypt: ageEncrypter,
+			header:  "-----BEGIN AGE ENCRYPTED FILE-----",
+			keys:    DecryptionKeys{AgeIdentities: []byte("# created: today\n" + identity.String() + "\n")},
+		},
+		{
+			name:    "age-other-recipient",
+			encrypt: ageEncrypter,
+			header:  "-----BEGIN AGE ENCRYPTED FILE-----",
+			keys:    DecryptionKeys{AgeIdentities: []byte(other.String())},
+		},
+		{
+			name:    "age-no-identity",
+			encrypt: ageEncrypter,
+			header:  "-----BEGIN AGE ENCRYPTED FILE-----",
+			err:     errors.New("input is encrypted with age, but no age identity is given"),
+		},
+		{
+			name:    "pgp",
+			encrypt: pgpEncrypter,
+			header:  "-----BEGIN PGP MESSAGE-----",
+			keys:    DecryptionKeys{PGPKeyring: privateKeyring.Bytes()},
+		},
+		{
+			name:    "pgp-no-keyring",
+			encrypt: pgpEncrypter,
+			header:  "-----BEGIN PGP MESSAGE-----",
+			keys:    DecryptionKeys{AgeIdentities: []byte(identity.String())},
+			err:     errors.New("input is encrypted with OpenPGP, but no OpenPGP keyring is given"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ciphertext, err := test.encrypt([]byte("a=b\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(ciphertext), test.header) {
+				t.Fatalf("expected output to start with %q but got %q", test.header, ciphertext)
+			}
+			r, err := DecryptInput(bytes.NewReader(ciphertext), test.keys)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				b, err := ioutil.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "a=b\n" {
+					t.Fatalf("expected response to be %q but got %q", "a=b\n", b)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestDecryptPGPSignature tests the DecryptInput function with signed
+// OpenPGP messages, whose signatures must match
+func TestDecryptPGPSignature(t *testing.T) {
+	t.Parallel()
+	entity, err := openpgp.NewEntity("k8shhh", "", "k8shhh@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keyring, message bytes.Buffer
+	if err := entity.Serialize(&keyring); err != nil {
+		t.Fatal(err)
+	}
+	w, err := openpgp.Sign(&message, entity, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("a=b\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the message ends with the signature, which no longer matches once its
+	// last byte is flipped
+	tampered := append([]byte{}, message.Bytes()...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name    string
+		message []byte
+		err     error
+	}{
+		{
+			name:    "valid",
+			message: message.Bytes(),
+		},
+		{
+			name:    "tampered",
+			message: tampered,
+			err:     errors.New("verifying OpenPGP signature: openpgp: invalid signature: RSA verification failure"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var armored bytes.Buffer
+			a, err := pgparmor.Encode(&armored, pgpMessageType, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.Write(test.message); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+			r, err := DecryptInput(&armored, DecryptionKeys{PGPKeyring: keyring.Bytes()})
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				b, err := ioutil.ReadAll(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != "a=b\n" {
+					t.Fatalf("expected response to be %q but got %q", "a=b\n", b)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestDecryptInput tests the DecryptInput function with inputs which are not
+// encrypted
+func TestDecryptInput(t *testing.T) {
+	t.Parallel()
+	for _, input := range []string{"", "a=b\n", "# -----BEGIN PGP MESSAGE-----\na=b"} {
+		r, err := DecryptInput(strings.NewReader(input), DecryptionKeys{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != input {
+			t.Fatalf("expected response to be %q but got %q", input, b)
+		}
+	}
+}
+
+// TestAgeEncrypterError tests the AgeEncrypter function with invalid
+// recipients
+func TestAgeEncrypterError(t *testing.T) {
+	t.Parallel()
+	if _, err := AgeEncrypter(); err == nil || err.Error() != "no age recipient given" {
+		t.Fatalf("expected error to be %q but got %q", "no age recipient given", err)
+	}
+	if _, err := AgeEncrypter("age1"); err == nil || !strings.HasPrefix(err.Error(), `invalid age recipient "age1"`) {
+		t.Fatalf("expected an invalid recipient error but got %q", err)
+	}
+}
+
+// TestPGPEncrypterUnsupported tests the PGPEncrypter function with an ECC
+// keyring, which is not supported
+func TestPGPEncrypterUnsupported(t *testing.T) {
+	t.Parallel()
+	expected := "reading OpenPGP keyring: openpgp: unsupported feature: public key type: 22 (only RSA, DSA and ElGamal keys are supported, not ECC keys such as ed25519 or cv25519)"
+	if _, err := PGPEncrypter([]byte(ed25519Keyring)); err == nil || err.Error() != expected {
+		t.Fatalf("expected error to be %q but got %q", expected, err)
+	}
+}
+
+// ed25519Keyring is an armored public keyring holding an ed25519 key with a
+// cv25519 subkey
+const ed25519Keyring = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatO78BYJKwYBBAHaRw8BAQdA0RIeqj6vi2wzw+UVyzLFearxgEmVck3qu+hc
+Q0iks9G0G2s4c2hoaCA8azhzaGhoQGV4YW1wbGUuY29tPoiQBBMWCAA4FiEEYn98
+FoSqR4Xx72jzZsyQPHJlwx0FAmrTu/ACGwMFCwkIBwIGFQoJCAsCBBYCAwECHgEC
+F4AACgkQZsyQPHJlwx2NTQEA2CluNkUtkU2gmlr5IKIJ7EZfXofACB338B1qAsqy
+WB0A/R3sYkWHIUy/0WDHvA9QsF7dAq46ooGGE7T9iJ92PbIKuDgEatO78BIKKwYB
+BAGXVQEFAQEHQIGaAZPX++29Le3Qb7Ekr21zZ8TE+drlVllUAkn1PYVEAwEIB4h4
+BBgWCAAgFiEEYn98FoSqR4Xx72jzZsyQPHJlwx0FAmrTu/ACGwwACgkQZsyQPHJl
+wx1KEAEAj9nzMLWxF4JOVnV4XVqbeLXmnoHo7Pj8NuZyQsWg/hcA/3PWM1M/66RH
+Wssd3MH/ipqeUG4ZpMytGeeHsZGHK1cL
+=AcGb
+-----END PGP PUBLIC KEY BLOCK-----
+`
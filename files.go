@@ -0,0 +1,107 @@
+package k8shhh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileData reads the given files into the data of a secret. Each source is
+// either a path, stored under a key named after the file, or of the form
+// key=path to choose the key explicitly. The raw bytes of the files are
+// stored, so binary files are supported.
+func FileData(sources ...string) (map[string]string, error) {
+	data := make(map[string]string)
+	for _, source := range sources {
+		key, path := "", source
+		if i := strings.Index(source, "="); i >= 0 {
+			key, path = source[:i], source[i+1:]
+			if key == "" {
+				return nil, fmt.Errorf("key in %q must not be empty", source)
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("path in %q must not be empty", source)
+		}
+		if key == "" {
+			key = filepath.Base(path)
+		}
+		value, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := addData(data, key, string(value)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// DirData reads the files of the given directory into the data of a secret,
+// each stored under a key named after the file. Symlinks are followed, as the
+// files of a mounted secret or config map are symlinks, and kept if their
+// target is a regular file. Dotfiles, subdirectories and other non-regular
+// files are skipped.
+func DirData(dir string) (map[string]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]string)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if entry.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			entry = target
+		}
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		value, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := addData(data, entry.Name(), string(value)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// MergeData merges the data of several sources into one, failing if a key
+// is present in more than one source
+func MergeData(sources ...map[string]string) (map[string]string, error) {
+	data := make(map[string]string)
+	for _, source := range sources {
+		for _, k := range sortedKeys(source) {
+			if err := addData(data, k, source[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
+
+// addData adds the value under the given key, after checking that the key
+// is a valid secret key which is not already present
+func addData(data map[string]string, key, value string) error {
+	if err := validateSecretKey(key); err != nil {
+		return ValidationError{Field: "key", Value: key, Reason: err.Error()}
+	}
+	if _, ok := data[key]; ok {
+		return fmt.Errorf("duplicate key %q", key)
+	}
+	data[key] = value
+	return nil
+}
@@ -0,0 +1,204 @@
+package k8shhh
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestFileData tests the FileData function
+func TestFileData(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "k8shhh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFile(t, filepath.Join(dir, "key.json"), `{"a":"b"}`)
+	writeTestFile(t, filepath.Join(dir, "keystore"), "\x00\xff\xfe")
+	writeTestFile(t, filepath.Join(dir, "invalid key"), "a")
+
+	tests := []struct {
+		name    string
+		sources []string
+		res     map[string]string
+		err     error
+	}{
+		{
+			name:    "file-name",
+			sources: []string{filepath.Join(dir, "key.json"), filepath.Join(dir, "keystore")},
+			res:     map[string]string{"key.json": `{"a":"b"}`, "keystore": "\x00\xff\xfe"},
+		},
+		{
+			name:    "explicit-key",
+			sources: []string{"service-account.json=" + filepath.Join(dir, "key.json")},
+			res:     map[string]string{"service-account.json": `{"a":"b"}`},
+		},
+		{
+			name:    "empty-key",
+			sources: []string{"=" + filepath.Join(dir, "key.json")},
+			err:     errors.New(`key in "=` + filepath.Join(dir, "key.json") + `" must not be empty`),
+		},
+		{
+			name:    "invalid-key",
+			sources: []string{filepath.Join(dir, "invalid key")},
+			err:     errors.New(`invalid key "invalid key": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+		{
+			name:    "duplicate-key",
+			sources: []string{filepath.Join(dir, "keystore"), "keystore=" + filepath.Join(dir, "key.json")},
+			err:     errors.New(`duplicate key "keystore"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := FileData(test.sources...)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestDirData tests the DirData function
+func TestDirData(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "k8shhh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	valid := filepath.Join(dir, "valid")
+	writeTestFile(t, filepath.Join(valid, "tls.crt"), "cert")
+	writeTestFile(t, filepath.Join(valid, "tls.key"), "key")
+	writeTestFile(t, filepath.Join(valid, ".hidden"), "hidden")
+	writeTestFile(t, filepath.Join(valid, "sub", "nested"), "nested")
+
+	invalid := filepath.Join(dir, "invalid")
+	writeTestFile(t, filepath.Join(invalid, "a@b"), "a")
+
+	// the layout of a mounted secret, whose files are symlinks to the files
+	// of a hidden directory
+	mounted := filepath.Join(dir, "mounted")
+	writeTestFile(t, filepath.Join(mounted, "..2020_06_01", "token"), "abc")
+	writeTestFile(t, filepath.Join(mounted, "..2020_06_01", "sub", "nested"), "nested")
+	for link, target := range map[string]string{
+		"..data":   "..2020_06_01",
+		"token":    filepath.Join("..data", "token"),
+		"sub":      filepath.Join("..data", "sub"),
+		"dangling": filepath.Join("..data", "missing"),
+	} {
+		if err := os.Symlink(target, filepath.Join(mounted, link)); err != nil {
+			t.Skipf("cannot create symlinks: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		res  map[string]string
+		err  error
+	}{
+		{
+			name: "valid",
+			dir:  valid,
+			res:  map[string]string{"tls.crt": "cert", "tls.key": "key"},
+		},
+		{
+			name: "symlinks",
+			dir:  mounted,
+			res:  map[string]string{"token": "abc"},
+		},
+		{
+			name: "invalid-key",
+			dir:  invalid,
+			err:  errors.New(`invalid key "a@b": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := DirData(test.dir)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestMergeData tests the MergeData function
+func TestMergeData(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		sources []map[string]string
+		res     map[string]string
+		err     error
+	}{
+		{
+			name:    "merge",
+			sources: []map[string]string{{"a": "b"}, nil, {"c": "d"}},
+			res:     map[string]string{"a": "b", "c": "d"},
+		},
+		{
+			name:    "duplicate",
+			sources: []map[string]string{{"a": "b"}, {"a": "c"}},
+			err:     errors.New(`duplicate key "a"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := MergeData(test.sources...)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// writeTestFile writes the content to the given file, creating its parent
+// directories
+func writeTestFile(t *testing.T, name, content string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
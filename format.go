@@ -0,0 +1,162 @@
+package k8shhh
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envNameRegexp matches the names of environment variables accepted by
+// POSIX shells and systemd
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Formatter is a type for function that renders the data of the given
+// secrets in a readable format
+type Formatter func([]Secret) ([]byte, error)
+
+// FormatJSON renders the data of the secret as a json object. When there
+// are several secrets, the data of each secret is nested under its name,
+// prefixed by its namespace if it has one.
+func FormatJSON(secrets []Secret) ([]byte, error) {
+	for _, secret := range secrets {
+		for _, k := range sortedKeys(secret.Data) {
+			if err := validateUTF8(k, secret.Data[k], "json"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	obj, err := nestSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(obj, "", "\t")
+}
+
+// FormatYAML renders the data of the secret as a yaml map. When there are
+// several secrets, the data of each secret is nested under its name,
+// prefixed by its namespace if it has one. Values which are not valid UTF-8
+// are base64 encoded with the !!binary tag.
+func FormatYAML(secrets []Secret) ([]byte, error) {
+	obj, err := nestSecrets(secrets)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(obj)
+}
+
+// FormatShell renders the data of the secrets as a shell script exporting
+// each key, with the values single quoted so that no character is expanded
+func FormatShell(secrets []Secret) ([]byte, error) {
+	return formatLines(secrets, func(k, v string) (string, error) {
+		if err := validateEnvLine(k, v, "a shell script"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("export %s='%s'", k, strings.Replace(v, "'", `'\''`, -1)), nil
+	})
+}
+
+// FormatDockerEnv renders the data of the secrets in the format of the
+// --env-file flag of `docker run`, which takes the values as is and thus
+// cannot hold multiline values
+func FormatDockerEnv(secrets []Secret) ([]byte, error) {
+	return formatLines(secrets, func(k, v string) (string, error) {
+		if strings.ContainsAny(v, "\n\r") {
+			return "", fmt.Errorf("value of key %q is multiline and cannot be written to a docker env file", k)
+		}
+		if strings.ContainsRune(v, 0) {
+			return "", fmt.Errorf("value of key %q contains a NUL byte", k)
+		}
+		if err := validateUTF8(k, v, "a docker env file"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s=%s", k, v), nil
+	})
+}
+
+// FormatSystemd renders the data of the secrets in the format of the
+// EnvironmentFile setting of systemd units, with the values double quoted
+func FormatSystemd(secrets []Secret) ([]byte, error) {
+	return formatLines(secrets, func(k, v string) (string, error) {
+		if err := validateEnvLine(k, v, "a systemd environment file"); err != nil {
+			return "", err
+		}
+		for _, c := range "\\\"`$" {
+			v = strings.Replace(v, string(c), `\`+string(c), -1)
+		}
+		return fmt.Sprintf(`%s="%s"`, k, v), nil
+	})
+}
+
+// formatLines renders each key of the secrets on its own line with the
+// given function, sorted by key. When there are several secrets, the lines
+// of each secret are grouped under a comment naming it.
+func formatLines(secrets []Secret, line func(k, v string) (string, error)) ([]byte, error) {
+	groups := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		lines := make([]string, 0, len(secret.Data)+1)
+		if len(secrets) > 1 {
+			lines = append(lines, fmt.Sprintf("# %s", qualifiedSecretName(secret)))
+		}
+		for _, k := range sortedKeys(secret.Data) {
+			l, err := line(k, secret.Data[k])
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, l)
+		}
+		groups = append(groups, strings.Join(lines, "\n"))
+	}
+	return []byte(strings.Join(groups, "\n\n")), nil
+}
+
+// nestSecrets returns the data of the single secret, or the data of each
+// secret keyed by its qualified name when there are several
+func nestSecrets(secrets []Secret) (interface{}, error) {
+	if len(secrets) == 1 {
+		return nonNilData(secrets[0]), nil
+	}
+	res := make(map[string]map[string]string, len(secrets))
+	for _, secret := range secrets {
+		name := qualifiedSecretName(secret)
+		if _, ok := res[name]; ok {
+			return nil, fmt.Errorf("duplicate secret %q", name)
+		}
+		res[name] = nonNilData(secret)
+	}
+	return res, nil
+}
+
+// nonNilData returns the data of the secret, which is empty rather than nil
+// so that it is rendered as an empty object
+func nonNilData(secret Secret) map[string]string {
+	if secret.Data == nil {
+		return map[string]string{}
+	}
+	return secret.Data
+}
+
+// validateEnvLine checks that the key is a valid environment variable name
+// and that the value can be held by an environment variable written to the
+// given target
+func validateEnvLine(k, v, target string) error {
+	if !envNameRegexp.MatchString(k) {
+		return fmt.Errorf("key %q is not a valid environment variable name", k)
+	}
+	if strings.ContainsRune(v, 0) {
+		return fmt.Errorf("value of key %q contains a NUL byte", k)
+	}
+	return validateUTF8(k, v, target)
+}
+
+// validateUTF8 checks that the value is valid UTF-8, so that binary values
+// are not written as garbage to the given target
+func validateUTF8(k, v, target string) error {
+	if !utf8.ValidString(v) {
+		return fmt.Errorf("value of key %q is not valid UTF-8 and cannot be written to %s", k, target)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package k8shhh
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestFormatters tests the FormatJSON, FormatYAML, FormatShell,
+// FormatDockerEnv and FormatSystemd functions
+func TestFormatters(t *testing.T) {
+	t.Parallel()
+	one := []Secret{{Name: "db", Data: map[string]string{"DB_HOST": "localhost", "QUOTE": `it's "$HOME"`}}}
+	several := []Secret{
+		{Name: "db", Namespace: "production", Data: map[string]string{"DB_HOST": "localhost"}},
+		{Name: "empty"},
+	}
+
+	tests := []struct {
+		name      string
+		formatter Formatter
+		secrets   []Secret
+		res       string
+		err       error
+	}{
+		{
+			name:      "json",
+			formatter: FormatJSON,
+			secrets:   one,
+			res:       "{\n\t\"DB_HOST\": \"localhost\",\n\t\"QUOTE\": \"it's \\\"$HOME\\\"\"\n}",
+		},
+		{
+			name:      "json-several",
+			formatter: FormatJSON,
+			secrets:   several,
+			res:       "{\n\t\"empty\": {},\n\t\"production/db\": {\n\t\t\"DB_HOST\": \"localhost\"\n\t}\n}",
+		},
+		{
+			name:      "json-binary",
+			formatter: FormatJSON,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "\xff"}}},
+			err:       errors.New(`value of key "KEY" is not valid UTF-8 and cannot be written to json`),
+		},
+		{
+			name:      "json-duplicate",
+			formatter: FormatJSON,
+			secrets:   []Secret{{Name: "db"}, {Name: "db"}},
+			err:       errors.New(`duplicate secret "db"`),
+		},
+		{
+			name:      "yaml",
+			formatter: FormatYAML,
+			secrets:   one,
+			res:       "DB_HOST: localhost\nQUOTE: it's \"$HOME\"\n",
+		},
+		{
+			name:      "yaml-several",
+			formatter: FormatYAML,
+			secrets:   several,
+			res:       "empty: {}\nproduction/db:\n  DB_HOST: localhost\n",
+		},
+		{
+			name:      "shell",
+			formatter: FormatShell,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"DB_HOST": "localhost", "QUOTE": `it's "$HOME"`, "MULTI": "a\nb"}}},
+			res:       "export DB_HOST='localhost'\nexport MULTI='a\nb'\nexport QUOTE='it'\\''s \"$HOME\"'",
+		},
+		{
+			name:      "shell-several",
+			formatter: FormatShell,
+			secrets:   several,
+			res:       "# production/db\nexport DB_HOST='localhost'\n\n# empty",
+		},
+		{
+			name:      "shell-invalid-name",
+			formatter: FormatShell,
+			secrets:   []Secret{{Name: "tls", Data: map[string]string{"tls.crt": "cert"}}},
+			err:       errors.New(`key "tls.crt" is not a valid environment variable name`),
+		},
+		{
+			name:      "shell-nul",
+			formatter: FormatShell,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "a\x00b"}}},
+			err:       errors.New(`value of key "KEY" contains a NUL byte`),
+		},
+		{
+			name:      "shell-binary",
+			formatter: FormatShell,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "\xff"}}},
+			err:       errors.New(`value of key "KEY" is not valid UTF-8 and cannot be written to a shell script`),
+		},
+		{
+			name:      "docker",
+			formatter: FormatDockerEnv,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"DB_HOST": "localhost", "QUOTE": `it's "$HOME"`, "tls.crt": "cert"}}},
+			res:       "DB_HOST=localhost\nQUOTE=it's \"$HOME\"\ntls.crt=cert",
+		},
+		{
+			name:      "docker-multiline",
+			formatter: FormatDockerEnv,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "a\nb"}}},
+			err:       errors.New(`value of key "KEY" is multiline and cannot be written to a docker env file`),
+		},
+		{
+			name:      "docker-binary",
+			formatter: FormatDockerEnv,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "\xff"}}},
+			err:       errors.New(`value of key "KEY" is not valid UTF-8 and cannot be written to a docker env file`),
+		},
+		{
+			name:      "systemd",
+			formatter: FormatSystemd,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"DB_HOST": "localhost", "QUOTE": `it's "$HOME" \ ` + "`", "MULTI": "a\nb"}}},
+			res:       "DB_HOST=\"localhost\"\nMULTI=\"a\nb\"\nQUOTE=\"it's \\\"\\$HOME\\\" \\\\ \\`\"",
+		},
+		{
+			name:      "systemd-invalid-name",
+			formatter: FormatSystemd,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"1KEY": "a"}}},
+			err:       errors.New(`key "1KEY" is not a valid environment variable name`),
+		},
+		{
+			name:      "systemd-binary",
+			formatter: FormatSystemd,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "\xff"}}},
+			err:       errors.New(`value of key "KEY" is not valid UTF-8 and cannot be written to a systemd environment file`),
+		},
+		{
+			name:      "yaml-binary",
+			formatter: FormatYAML,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "\xff"}}},
+			res:       "KEY: !!binary /w==\n",
+		},
+		{
+			name:      "env",
+			formatter: FormatSecrets,
+			secrets:   one,
+			res:       "DB_HOST=localhost\nQUOTE=\"it's \\\"\\$HOME\\\"\"",
+		},
+		{
+			name:      "env-binary",
+			formatter: FormatSecrets,
+			secrets:   []Secret{{Name: "db", Data: map[string]string{"KEY": "\xff"}}},
+			res:       "KEY=/w== # k8shhh:binary",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := test.formatter(test.secrets)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if string(res) != test.res {
+					t.Fatalf("expected response to be %q but got %q", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
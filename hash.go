@@ -0,0 +1,68 @@
+package k8shhh
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// SecretHash returns the hash of the name, the type and the data of the
+// secret, computed in the same way as the secretGenerator of kustomize does,
+// so that both name the same content identically
+func SecretHash(secret Secret) string {
+	secretType := secret.Type
+	if secretType == "" {
+		secretType = SecretTypeOpaque
+	}
+	// kustomize hashes a secret without data as an empty string
+	var data interface{} = ""
+	if len(secret.Data) > 0 {
+		values := make(map[string][]byte, len(secret.Data))
+		for k, v := range secret.Data {
+			values[k] = []byte(v)
+		}
+		data = values
+	}
+	// json.Marshal sorts the keys of maps, which makes the encoding stable,
+	// and cannot fail on these values
+	b, _ := json.Marshal(map[string]interface{}{
+		"kind": "Secret",
+		"type": secretType,
+		"name": secret.Name,
+		"data": data,
+	})
+	return encodeHash(fmt.Sprintf("%x", sha256.Sum256(b)))
+}
+
+// withHashSuffix returns the secret with the hash of its content appended to
+// its name and made immutable, if its HashSuffix is set
+func withHashSuffix(secret Secret) Secret {
+	if !secret.HashSuffix {
+		return secret
+	}
+	secret.Name = secret.Name + "-" + SecretHash(secret)
+	secret.Immutable = true
+	secret.HashSuffix = false
+	return secret
+}
+
+// encodeHash keeps the first ten characters of the hex encoded hash, with
+// the characters which may form words mapped to consonants, as kustomize does
+func encodeHash(hex string) string {
+	enc := []rune(hex[:10])
+	for i := range enc {
+		switch enc[i] {
+		case '0':
+			enc[i] = 'g'
+		case '1':
+			enc[i] = 'h'
+		case '3':
+			enc[i] = 'k'
+		case 'a':
+			enc[i] = 'm'
+		case 'e':
+			enc[i] = 't'
+		}
+	}
+	return string(enc)
+}
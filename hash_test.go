@@ -0,0 +1,46 @@
+package k8shhh
+
+import (
+	"testing"
+)
+
+// TestSecretHash tests the SecretHash function against the hashes computed
+// by kustomize for the same secrets
+func TestSecretHash(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		secret Secret
+		res    string
+	}{
+		{
+			name:   "empty-data",
+			secret: Secret{Type: "my-type"},
+			res:    "5gmgkf8578",
+		},
+		{
+			name:   "one-key",
+			secret: Secret{Type: "my-type", Data: map[string]string{"one": ""}},
+			res:    "74bd68bm66",
+		},
+		{
+			name:   "three-keys",
+			secret: Secret{Type: "my-type", Data: map[string]string{"two": "2", "one": "", "three": "3"}},
+			res:    "dgcb6h9tmk",
+		},
+		{
+			name:   "default-type",
+			secret: Secret{Name: "yaml-hashed", Data: map[string]string{"a": "b"}},
+			res:    "mh777g8986",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if res := SecretHash(test.secret); res != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
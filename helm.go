@@ -0,0 +1,171 @@
+package k8shhh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// helmChartLabels are the standard labels of the objects of a helm chart,
+// as generated by `helm create`
+const helmChartLabels = `    helm.sh/chart: {{ printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/name: {{ .Chart.Name | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/instance: {{ .Release.Name }}
+    {{- if .Chart.AppVersion }}
+    app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+    {{- end }}
+    app.kubernetes.io/managed-by: {{ .Release.Service }}
+`
+
+// helmChartLabelKeys are the keys of helmChartLabels, which the labels of the
+// secret must not override
+var helmChartLabelKeys = []string{
+	"helm.sh/chart",
+	"app.kubernetes.io/name",
+	"app.kubernetes.io/instance",
+	"app.kubernetes.io/version",
+	"app.kubernetes.io/managed-by",
+}
+
+// helmIdentifierRegexp matches the keys which can be referred to as fields
+// in a helm template, such as .Values.secrets.KEY
+var helmIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// HelmEncoder returns an encoder which outputs the secret to a helm
+// template, whose values are read from .Values.secrets when the chart is
+// rendered. The values are written according to the given mode, either
+// base64 encoded with b64enc under data or quoted under stringData. The
+// secret is placed in the namespace of the release unless it has one, and
+// is given the standard labels of a chart along with its own, which must not
+// override them. Template delimiters in the name, labels, annotations and
+// type are escaped, so that they are written as is rather than run by helm.
+// HelmValues returns the matching values.
+func HelmEncoder(mode DataMode) Encoder {
+	return func(secret Secret) ([]byte, error) {
+		if secret.HashSuffix {
+			return nil, errors.New("hash suffixed names are not supported by helm templates, as the values are only known when the chart is rendered")
+		}
+		if secret.Type == "" {
+			secret.Type = SecretTypeOpaque
+		}
+		if err := ValidateSecret(secret); err != nil {
+			return nil, err
+		}
+		if err := validateType(secret); err != nil {
+			return nil, err
+		}
+		if err := validateHelmValues(secret.Data); err != nil {
+			return nil, err
+		}
+		for _, k := range helmChartLabelKeys {
+			if _, ok := secret.Labels[k]; ok {
+				return nil, fmt.Errorf("label %q is set by the chart and cannot be overridden", k)
+			}
+		}
+		obj, err := NewSecretObject(secret, mode)
+		if err != nil {
+			return nil, err
+		}
+
+		var b strings.Builder
+		b.WriteString("apiVersion: v1\nkind: Secret\nmetadata:\n")
+		fmt.Fprintf(&b, "  name: %s\n", helmScalar(obj.Name))
+		if obj.Namespace != "" {
+			fmt.Fprintf(&b, "  namespace: %s\n", helmScalar(obj.Namespace))
+		} else {
+			b.WriteString("  namespace: {{ .Release.Namespace }}\n")
+		}
+		b.WriteString("  labels:\n" + helmChartLabels)
+		for _, k := range sortedKeys(obj.Labels) {
+			fmt.Fprintf(&b, "    %s: %s\n", helmScalar(k), helmScalar(obj.Labels[k]))
+		}
+		if len(obj.Annotations) > 0 {
+			b.WriteString("  annotations:\n")
+			for _, k := range sortedKeys(obj.Annotations) {
+				fmt.Fprintf(&b, "    %s: %s\n", helmScalar(k), helmScalar(obj.Annotations[k]))
+			}
+		}
+		if obj.Immutable != nil && *obj.Immutable {
+			b.WriteString("immutable: true\n")
+		}
+		fmt.Fprintf(&b, "type: %s\n", helmScalar(string(obj.Type)))
+		writeHelmData(&b, "data", sortedByteKeys(obj.Data), "b64enc")
+		writeHelmData(&b, "stringData", sortedKeys(obj.StringData), "quote")
+		return []byte(b.String()), nil
+	}
+}
+
+// HelmValues returns the values.yaml fragment of the secret read by the
+// template of HelmEncoder, which holds its data under secrets
+func HelmValues(secret Secret) ([]byte, error) {
+	if err := validateHelmValues(secret.Data); err != nil {
+		return nil, err
+	}
+	data := secret.Data
+	if data == nil {
+		data = map[string]string{}
+	}
+	return yaml.Marshal(map[string]map[string]string{"secrets": data})
+}
+
+// writeHelmData writes the keys under the given field of the template, each
+// with the value of the key in .Values.secrets piped to the given function
+func writeHelmData(b *strings.Builder, field string, keys []string, function string) {
+	if len(keys) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s:\n", field)
+	for _, k := range keys {
+		value := ".Values.secrets." + k
+		if !helmIdentifierRegexp.MatchString(k) {
+			value = fmt.Sprintf("index .Values.secrets %q", k)
+		}
+		fmt.Fprintf(b, "  %s: {{ %s | %s }}\n", helmScalar(k), value, function)
+	}
+}
+
+// validateHelmValues checks that the values can be written to a values.yaml
+// file, which requires them to be valid UTF-8
+func validateHelmValues(data map[string]string) error {
+	for _, k := range sortedKeys(data) {
+		if err := validateUTF8(k, data[k], "helm values"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// helmScalar returns the value as a yaml scalar written on a single line,
+// quoted when needed, with its template delimiters escaped
+func helmScalar(v string) string {
+	b, err := yaml.Marshal(v)
+	s := strings.TrimSuffix(string(b), "\n")
+	if err != nil || strings.Contains(s, "\n") {
+		// a json string is a double quoted yaml scalar
+		b, _ = json.Marshal(v)
+		s = string(b)
+	}
+	return helmEscape(s)
+}
+
+// helmEscape escapes the template delimiters of the text, which helm then
+// renders as is. Only {{ needs escaping, as }} is plain text outside of an
+// action.
+func helmEscape(s string) string {
+	return strings.Replace(s, "{{", `{{ "{{" }}`, -1)
+}
+
+// sortedByteKeys returns the keys of the map in sorted order
+func sortedByteKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
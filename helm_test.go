@@ -0,0 +1,193 @@
+package k8shhh
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestHelmEncoder tests the HelmEncoder function
+func TestHelmEncoder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		mode   DataMode
+		secret Secret
+		res    string
+		err    error
+	}{
+		{
+			name:   "data",
+			mode:   DataModeData,
+			secret: Secret{Name: "db", Labels: map[string]string{"team": "a"}, Immutable: true, Data: map[string]string{"DB_HOST": "localhost", "tls.extra": "x"}},
+			res:    successHelmTemplateData,
+		},
+		{
+			name:   "hybrid",
+			mode:   DataModeHybrid,
+			secret: Secret{Name: "db", Namespace: "prod", Annotations: map[string]string{"note": "a\nb"}, Data: map[string]string{"A": "b", "BIN": "\x00"}},
+			res:    successHelmTemplateHybrid,
+		},
+		{
+			name:   "delimiters",
+			mode:   DataModeData,
+			secret: Secret{Name: "db", Labels: map[string]string{"team": "a"}, Annotations: map[string]string{"note": "{{ .Values.evil }}", "multi": "{{-\n}}"}, Type: "example.com/{{x}}", Data: map[string]string{"A": "b"}},
+			res:    successHelmTemplateDelimiters,
+		},
+		{
+			name:   "chart-label",
+			mode:   DataModeData,
+			secret: Secret{Name: "db", Labels: map[string]string{"app.kubernetes.io/name": "db"}, Data: map[string]string{"A": "b"}},
+			err:    errors.New(`label "app.kubernetes.io/name" is set by the chart and cannot be overridden`),
+		},
+		{
+			name:   "hash-suffix",
+			mode:   DataModeData,
+			secret: Secret{Name: "db", HashSuffix: true, Data: map[string]string{"A": "b"}},
+			err:    errors.New("hash suffixed names are not supported by helm templates, as the values are only known when the chart is rendered"),
+		},
+		{
+			name:   "binary",
+			mode:   DataModeData,
+			secret: Secret{Name: "db", Data: map[string]string{"A": "\xff"}},
+			err:    errors.New(`value of key "A" is not valid UTF-8 and cannot be written to helm values`),
+		},
+		{
+			name:   "invalid-name",
+			mode:   DataModeData,
+			secret: Secret{Name: "DB", Data: map[string]string{"A": "b"}},
+			err:    errors.New(`invalid name "DB": must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := HelmEncoder(test.mode)(test.secret)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+// TestHelmValues tests the HelmValues function
+func TestHelmValues(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		secret Secret
+		res    string
+		err    error
+	}{
+		{
+			name:   "values",
+			secret: Secret{Name: "db", Data: map[string]string{"DB_HOST": "localhost", "PORT": "5432", "MULTI": "a\nb"}},
+			res:    "secrets:\n  DB_HOST: localhost\n  MULTI: |-\n    a\n    b\n  PORT: \"5432\"\n",
+		},
+		{
+			name:   "empty",
+			secret: Secret{Name: "db"},
+			res:    "secrets: {}\n",
+		},
+		{
+			name:   "binary",
+			secret: Secret{Name: "db", Data: map[string]string{"A": "\xff"}},
+			err:    errors.New(`value of key "A" is not valid UTF-8 and cannot be written to helm values`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := HelmValues(test.secret)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if string(res) != test.res {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+		})
+	}
+}
+
+const (
+	successHelmTemplateData = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: {{ .Release.Namespace }}
+  labels:
+    helm.sh/chart: {{ printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/name: {{ .Chart.Name | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/instance: {{ .Release.Name }}
+    {{- if .Chart.AppVersion }}
+    app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+    {{- end }}
+    app.kubernetes.io/managed-by: {{ .Release.Service }}
+    team: a
+immutable: true
+type: Opaque
+data:
+  DB_HOST: {{ .Values.secrets.DB_HOST | b64enc }}
+  tls.extra: {{ index .Values.secrets "tls.extra" | b64enc }}
+`
+
+	successHelmTemplateHybrid = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+  labels:
+    helm.sh/chart: {{ printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/name: {{ .Chart.Name | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/instance: {{ .Release.Name }}
+    {{- if .Chart.AppVersion }}
+    app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+    {{- end }}
+    app.kubernetes.io/managed-by: {{ .Release.Service }}
+  annotations:
+    note: "a\nb"
+type: Opaque
+data:
+  BIN: {{ .Values.secrets.BIN | b64enc }}
+stringData:
+  A: {{ .Values.secrets.A | quote }}
+`
+
+	successHelmTemplateDelimiters = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: {{ .Release.Namespace }}
+  labels:
+    helm.sh/chart: {{ printf "%s-%s" .Chart.Name .Chart.Version | replace "+" "_" | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/name: {{ .Chart.Name | trunc 63 | trimSuffix "-" }}
+    app.kubernetes.io/instance: {{ .Release.Name }}
+    {{- if .Chart.AppVersion }}
+    app.kubernetes.io/version: {{ .Chart.AppVersion | quote }}
+    {{- end }}
+    app.kubernetes.io/managed-by: {{ .Release.Service }}
+    team: a
+  annotations:
+    multi: "{{ "{{" }}-\n}}"
+    note: '{{ "{{" }} .Values.evil }}'
+type: example.com/{{ "{{" }}x}}
+data:
+  A: {{ .Values.secrets.A | b64enc }}
+`
+)
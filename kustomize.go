@@ -0,0 +1,516 @@
+package k8shhh
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// kustomizationFileNames are the names of the kustomization file of a
+// directory, in the order kustomize looks for them
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// GeneratorBehavior determines how a generated secret relates to the secret
+// of the same name found in the resources of a kustomization
+type GeneratorBehavior string
+
+const (
+	// GeneratorBehaviorCreate creates a new secret, which must not already
+	// exist (default)
+	GeneratorBehaviorCreate GeneratorBehavior = "create"
+	// GeneratorBehaviorMerge merges the generated data into the existing
+	// secret, overriding the keys present in both
+	GeneratorBehaviorMerge GeneratorBehavior = "merge"
+	// GeneratorBehaviorReplace replaces the data of the existing secret
+	GeneratorBehaviorReplace GeneratorBehavior = "replace"
+)
+
+// Kustomization is the part of a kustomization.yaml evaluated to generate
+// secrets. Its other fields are ignored.
+type Kustomization struct {
+	Resources         []string              `yaml:"resources,omitempty"`
+	Bases             []string              `yaml:"bases,omitempty"`
+	Namespace         string                `yaml:"namespace,omitempty"`
+	NamePrefix        string                `yaml:"namePrefix,omitempty"`
+	NameSuffix        string                `yaml:"nameSuffix,omitempty"`
+	CommonLabels      map[string]string     `yaml:"commonLabels,omitempty"`
+	CommonAnnotations map[string]string     `yaml:"commonAnnotations,omitempty"`
+	GeneratorOptions  *GeneratorOptions     `yaml:"generatorOptions,omitempty"`
+	SecretGenerator   []SecretGeneratorArgs `yaml:"secretGenerator,omitempty"`
+}
+
+// SecretGeneratorArgs is an entry of the secretGenerator of a kustomization.
+// The data of the secret is read from the env files, the literals of the
+// form key=value and the files of the form [key=]path, in that order.
+type SecretGeneratorArgs struct {
+	Name      string            `yaml:"name,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Behavior  GeneratorBehavior `yaml:"behavior,omitempty"`
+	Literals  []string          `yaml:"literals,omitempty"`
+	Files     []string          `yaml:"files,omitempty"`
+	Envs      []string          `yaml:"envs,omitempty"`
+	// Env is the older, singular form of Envs
+	Env     string            `yaml:"env,omitempty"`
+	Type    SecretType        `yaml:"type,omitempty"`
+	Options *GeneratorOptions `yaml:"options,omitempty"`
+}
+
+// GeneratorOptions are the options of the generated secrets, given for all
+// of them in the generatorOptions of a kustomization, or for a single one
+type GeneratorOptions struct {
+	Labels                map[string]string `yaml:"labels,omitempty"`
+	Annotations           map[string]string `yaml:"annotations,omitempty"`
+	DisableNameSuffixHash bool              `yaml:"disableNameSuffixHash,omitempty"`
+	Immutable             bool              `yaml:"immutable,omitempty"`
+}
+
+// kustomizedSecret is a secret generated or found in the resources while
+// evaluating a kustomization
+type kustomizedSecret struct {
+	Secret
+	// id is the name of the secret before any prefix or suffix is added to
+	// it, which the generators merging or replacing it refer to
+	id string
+	// hash is set when the hash of the secret is to be appended to its name
+	// once the kustomization is evaluated
+	hash bool
+}
+
+// KustomizeSecrets evaluates the kustomization of the given directory in
+// the same way as `kustomize build` does, and returns the secrets generated
+// by its secretGenerator along with the secrets of its resources. The
+// resources are either directories holding a kustomization, evaluated in
+// turn, or manifests whose secrets are read. The names of the generated
+// secrets are suffixed with the hash of their content, unless the
+// disableNameSuffixHash option is set.
+//
+// Env files are parsed with the rules of kustomize v5, taking the rest of each
+// line after the = as is, with no quotes, escapes or variables. They are
+// decrypted with the given keys if they are encrypted. Like kustomize,
+// the files read must lie in or below the directory of the kustomization.
+func KustomizeSecrets(dir string, keys DecryptionKeys) ([]Secret, error) {
+	secrets, err := kustomizeDir(dir, keys, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Secret, 0, len(secrets))
+	for _, s := range secrets {
+		if s.hash {
+			s.Name = s.Name + "-" + SecretHash(s.Secret)
+		}
+		res = append(res, s.Secret)
+	}
+	return res, nil
+}
+
+// ReadKustomization reads the kustomization file of the given directory
+func ReadKustomization(dir string) (Kustomization, error) {
+	for _, name := range kustomizationFileNames {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return Kustomization{}, err
+		}
+		var k Kustomization
+		if err := yaml.Unmarshal(b, &k); err != nil {
+			return Kustomization{}, fmt.Errorf("%s: %v", filepath.Join(dir, name), err)
+		}
+		return k, nil
+	}
+	return Kustomization{}, fmt.Errorf("no kustomization.yaml, kustomization.yml or Kustomization found in %q", dir)
+}
+
+// SecretGeneratorStanza returns the secretGenerator of a kustomization
+// holding the given entries, as written to a kustomization.yaml
+func SecretGeneratorStanza(args ...SecretGeneratorArgs) ([]byte, error) {
+	return yaml.Marshal(Kustomization{SecretGenerator: args})
+}
+
+// LiteralSources returns the data of a secret as the literals of a
+// secretGenerator, sorted by key. The values which kustomize would unquote
+// are quoted once more, so that they are read back unchanged.
+func LiteralSources(data map[string]string) ([]string, error) {
+	literals := make([]string, 0, len(data))
+	for _, k := range sortedKeys(data) {
+		v := data[k]
+		if err := validateUTF8(k, v, "a literal"); err != nil {
+			return nil, err
+		}
+		if isQuotedLiteral(v) {
+			v = `"` + v + `"`
+		}
+		literals = append(literals, k+"="+v)
+	}
+	return literals, nil
+}
+
+// KustomizeEnvMismatches returns the keys of the dotenv formatted input
+// whose values are read differently by kustomize, which takes the rest of
+// each line after the = as is, with no quotes, escapes or variables. An error
+// is returned if kustomize cannot read the input at all, e.g. as a line is
+// prefixed by export.
+func KustomizeEnvMismatches(input []byte) ([]string, error) {
+	data, err := parseDotenv(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	kustomized, err := parseKustomizeEnv(input)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize cannot read the input: %v", err)
+	}
+	var keys []string
+	for _, k := range sortedKeys(data) {
+		if v, ok := kustomized[k]; !ok || v != data[k] {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// kustomizeDir evaluates the kustomization of the given directory. The
+// directories being evaluated are tracked to detect cycles.
+func kustomizeDir(dir string, keys DecryptionKeys, visiting map[string]bool) ([]kustomizedSecret, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if visiting[abs] {
+		return nil, fmt.Errorf("cycle detected at %q", dir)
+	}
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	k, err := ReadKustomization(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var secrets []kustomizedSecret
+	for _, r := range append(append([]string{}, k.Bases...), k.Resources...) {
+		res, err := kustomizeResource(dir, r, keys, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("resource %q: %v", r, err)
+		}
+		secrets = append(secrets, res...)
+	}
+
+	for _, args := range k.SecretGenerator {
+		generated, err := generateSecret(dir, args, k.GeneratorOptions, keys)
+		if err != nil {
+			return nil, fmt.Errorf("secretGenerator %q: %v", args.Name, err)
+		}
+		if secrets, err = addGenerated(secrets, generated, args.Behavior); err != nil {
+			return nil, fmt.Errorf("secretGenerator %q: %v", args.Name, err)
+		}
+	}
+
+	for i := range secrets {
+		s := &secrets[i]
+		s.Name = k.NamePrefix + s.Name + k.NameSuffix
+		if k.Namespace != "" {
+			s.Namespace = k.Namespace
+		}
+		s.Labels = mergeStringMaps(s.Labels, k.CommonLabels)
+		s.Annotations = mergeStringMaps(s.Annotations, k.CommonAnnotations)
+	}
+	return secrets, nil
+}
+
+// kustomizeResource returns the secrets of a resource of the kustomization
+// in dir, which is either a directory holding a kustomization or a manifest
+func kustomizeResource(dir, resource string, keys DecryptionKeys, visiting map[string]bool) ([]kustomizedSecret, error) {
+	if strings.Contains(resource, "://") || strings.HasPrefix(resource, "github.com/") || strings.HasPrefix(resource, "git@") {
+		return nil, errors.New("remote resources are not supported")
+	}
+	path := resource
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return kustomizeDir(path, keys, visiting)
+	}
+
+	if path, err = kustomizePath(dir, resource); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := DecodeYAML(f)
+	if err != nil {
+		return nil, err
+	}
+	var c collector
+	if err := c.collect(doc); err != nil {
+		return nil, err
+	}
+	secrets := make([]kustomizedSecret, 0, len(c.objects))
+	for _, obj := range c.objects {
+		secret := obj.Secret()
+		secrets = append(secrets, kustomizedSecret{Secret: secret, id: secret.Name})
+	}
+	return secrets, nil
+}
+
+// generateSecret generates the secret of a secretGenerator entry of the
+// kustomization in dir, whose options are merged with the given global ones
+func generateSecret(dir string, args SecretGeneratorArgs, global *GeneratorOptions, keys DecryptionKeys) (kustomizedSecret, error) {
+	if args.Name == "" {
+		return kustomizedSecret{}, errors.New("name must not be empty")
+	}
+
+	var sources []map[string]string
+	envs := args.Envs
+	if args.Env != "" {
+		envs = append(append([]string{}, envs...), args.Env)
+	}
+	for _, env := range envs {
+		path, err := kustomizePath(dir, env)
+		if err != nil {
+			return kustomizedSecret{}, err
+		}
+		data, err := readEnvFile(path, keys)
+		if err != nil {
+			return kustomizedSecret{}, fmt.Errorf("env file %q: %v", env, err)
+		}
+		sources = append(sources, data)
+	}
+
+	literals := make(map[string]string)
+	for _, literal := range args.Literals {
+		i := strings.Index(literal, "=")
+		if i <= 0 {
+			return kustomizedSecret{}, fmt.Errorf("invalid literal %q, expected key=value", literal)
+		}
+		if err := addData(literals, literal[:i], unquoteLiteral(literal[i+1:])); err != nil {
+			return kustomizedSecret{}, err
+		}
+	}
+	sources = append(sources, literals)
+
+	files := make([]string, 0, len(args.Files))
+	for _, source := range args.Files {
+		key, name := "", source
+		if i := strings.Index(source, "="); i >= 0 {
+			key, name = source[:i+1], source[i+1:]
+		}
+		if key == "" {
+			key = filepath.Base(name) + "="
+		}
+		path, err := kustomizePath(dir, name)
+		if err != nil {
+			return kustomizedSecret{}, err
+		}
+		files = append(files, key+path)
+	}
+	data, err := FileData(files...)
+	if err != nil {
+		return kustomizedSecret{}, err
+	}
+	sources = append(sources, data)
+
+	if data, err = MergeData(sources...); err != nil {
+		return kustomizedSecret{}, err
+	}
+
+	options := GeneratorOptions{}
+	if args.Options != nil {
+		options = *args.Options
+	}
+	if global != nil {
+		options.Labels = mergeStringMaps(global.Labels, options.Labels)
+		options.Annotations = mergeStringMaps(global.Annotations, options.Annotations)
+		options.DisableNameSuffixHash = options.DisableNameSuffixHash || global.DisableNameSuffixHash
+		options.Immutable = options.Immutable || global.Immutable
+	}
+	return kustomizedSecret{
+		Secret: Secret{
+			Name:        args.Name,
+			Namespace:   args.Namespace,
+			Labels:      options.Labels,
+			Annotations: options.Annotations,
+			Type:        args.Type,
+			Immutable:   options.Immutable,
+			Data:        data,
+		},
+		id:   args.Name,
+		hash: !options.DisableNameSuffixHash,
+	}, nil
+}
+
+// addGenerated adds the generated secret to the secrets of a kustomization
+// according to the behavior of its generator. A secret merged or replaced
+// keeps its name and namespace, and has its labels and annotations merged
+// with the generated ones.
+func addGenerated(secrets []kustomizedSecret, generated kustomizedSecret, behavior GeneratorBehavior) ([]kustomizedSecret, error) {
+	var matches []int
+	for i, s := range secrets {
+		if (s.id == generated.id || s.Name == generated.id) && effectiveNamespace(s.Namespace) == effectiveNamespace(generated.Namespace) {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("found %d secrets which could be merged", len(matches))
+	}
+
+	switch behavior {
+	case GeneratorBehaviorCreate, "":
+		if len(matches) > 0 {
+			return nil, errors.New("secret already exists, so the behavior must be merge or replace")
+		}
+		return append(secrets, generated), nil
+	case GeneratorBehaviorMerge, GeneratorBehaviorReplace:
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("secret does not exist, so it cannot be %sd", behavior)
+		}
+	default:
+		return nil, fmt.Errorf("unknown behavior %q", behavior)
+	}
+
+	old := secrets[matches[0]]
+	generated.Name = old.Name
+	generated.Namespace = old.Namespace
+	generated.id = old.id
+	generated.Labels = mergeStringMaps(old.Labels, generated.Labels)
+	generated.Annotations = mergeStringMaps(old.Annotations, generated.Annotations)
+	if behavior == GeneratorBehaviorMerge {
+		generated.Data = mergeStringMaps(old.Data, generated.Data)
+	}
+	res := append([]kustomizedSecret{}, secrets...)
+	res[matches[0]] = generated
+	return res, nil
+}
+
+// kustomizePath returns the path of the named file of the kustomization in
+// dir, which must lie in or below dir
+func kustomizePath(dir, name string) (string, error) {
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %q is not in or below the directory of the kustomization", name)
+	}
+	return path, nil
+}
+
+// readEnvFile reads the data of the named env file, decrypting it with the
+// given keys if it is encrypted
+func readEnvFile(name string, keys DecryptionKeys) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	input, err := DecryptInput(f, keys)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return parseKustomizeEnv(b)
+}
+
+// parseKustomizeEnv parses the env file input with the rules of kustomize v5.
+// Blank lines and lines starting with # are skipped, and each other line is
+// split at its first =, the rest of the line being the value as is. A line
+// without = holds a key with an empty value, and keys are checked as secret
+// keys. kustomize v4 and kubectl differ on both points, as they read the value
+// of such a line from the environment, and require keys to be environment
+// variable names, which cannot start with a digit.
+func parseKustomizeEnv(input []byte) (map[string]string, error) {
+	data := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(input))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if !utf8.Valid(line) {
+			return nil, fmt.Errorf("line %d is not valid UTF-8", n)
+		}
+		if n == 1 {
+			line = bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+		}
+		line = bytes.TrimLeftFunc(line, unicode.IsSpace)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		kv := strings.SplitN(string(line), "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		if err := addData(data, kv[0], value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unquoteLiteral removes the quotes surrounding the value of a literal, as
+// kustomize does
+func unquoteLiteral(v string) string {
+	if isQuotedLiteral(v) {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// isQuotedLiteral checks whether the value of a literal is surrounded by
+// single or double quotes
+func isQuotedLiteral(v string) bool {
+	return len(v) > 1 && v[0] == v[len(v)-1] && (v[0] == '"' || v[0] == '\'')
+}
+
+// effectiveNamespace returns the namespace a secret is created in
+func effectiveNamespace(namespace string) string {
+	if namespace == "" {
+		return "default"
+	}
+	return namespace
+}
+
+// mergeStringMaps returns the entries of both maps, with those of the
+// second taking precedence. It returns nil if both are empty.
+func mergeStringMaps(m, override map[string]string) map[string]string {
+	if len(m) == 0 && len(override) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(m)+len(override))
+	for k, v := range m {
+		res[k] = v
+	}
+	for k, v := range override {
+		res[k] = v
+	}
+	return res
+}
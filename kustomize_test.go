@@ -0,0 +1,347 @@
+package k8shhh
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestKustomizeSecrets tests the KustomizeSecrets function
+func TestKustomizeSecrets(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "k8shhh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, filepath.Join(dir, "base", "kustomization.yaml"), `resources:
+- deployment.yaml
+- token.yaml
+generatorOptions:
+  labels:
+    app: web
+secretGenerator:
+- name: app
+  envs:
+  - app.env
+  literals:
+  - MODE="debug"
+- name: tls
+  type: kubernetes.io/tls
+  files:
+  - tls.crt=cert.pem
+  - tls.key
+  options:
+    disableNameSuffixHash: true
+`)
+	writeTestFile(t, filepath.Join(dir, "base", "app.env"), "DB_HOST=localhost\nDB_PASS=\"s3cr3t\"\n# comment\n\n  PASSWORD=abc$DEF#x 'y' \n")
+	writeTestFile(t, filepath.Join(dir, "base", "cert.pem"), "cert")
+	writeTestFile(t, filepath.Join(dir, "base", "tls.key"), "key")
+	writeTestFile(t, filepath.Join(dir, "base", "deployment.yaml"), "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n")
+	writeTestFile(t, filepath.Join(dir, "base", "token.yaml"), "apiVersion: v1\nkind: Secret\nmetadata:\n  name: token\nstringData:\n  TOKEN: abc\n")
+
+	writeTestFile(t, filepath.Join(dir, "merge", "kustomization.yaml"), `namespace: prod
+namePrefix: prod-
+commonLabels:
+  env: prod
+resources:
+- ../base
+secretGenerator:
+- name: app
+  behavior: merge
+  literals:
+  - DB_HOST=db.prod
+  options:
+    immutable: true
+- name: token
+  behavior: replace
+  literals:
+  - TOKEN=xyz
+  options:
+    disableNameSuffixHash: true
+`)
+	writeTestFile(t, filepath.Join(dir, "invalid-env", "kustomization.yaml"), "secretGenerator:\n- name: app\n  envs:\n  - app.env\n")
+	writeTestFile(t, filepath.Join(dir, "invalid-env", "app.env"), "A=b\nexport B=c\n")
+	writeTestFile(t, filepath.Join(dir, "create-existing", "kustomization.yaml"), "resources:\n- ../base\nsecretGenerator:\n- name: app\n  literals:\n  - A=b\n")
+	writeTestFile(t, filepath.Join(dir, "merge-missing", "kustomization.yaml"), "secretGenerator:\n- name: app\n  behavior: merge\n  literals:\n  - A=b\n")
+	writeTestFile(t, filepath.Join(dir, "outside", "kustomization.yaml"), "secretGenerator:\n- name: app\n  envs:\n  - ../base/app.env\n")
+	writeTestFile(t, filepath.Join(dir, "remote", "kustomization.yaml"), "resources:\n- https://github.com/example/config\n")
+	writeTestFile(t, filepath.Join(dir, "invalid-literal", "kustomization.yaml"), "secretGenerator:\n- name: app\n  literals:\n  - =b\n")
+	writeTestFile(t, filepath.Join(dir, "cycle", "kustomization.yaml"), "resources:\n- .\n")
+	writeTestFile(t, filepath.Join(dir, "empty", "README"), "")
+
+	tests := []struct {
+		name string
+		dir  string
+		res  []Secret
+		err  error
+	}{
+		{
+			name: "base",
+			dir:  "base",
+			res: []Secret{
+				{Name: "token", Data: map[string]string{"TOKEN": "abc"}},
+				{Name: "app-cmgf4mf97m", Labels: map[string]string{"app": "web"}, Data: map[string]string{"DB_HOST": "localhost", "DB_PASS": `"s3cr3t"`, "MODE": "debug", "PASSWORD": "abc$DEF#x 'y' "}},
+				{Name: "tls", Labels: map[string]string{"app": "web"}, Type: SecretTypeTLS, Data: map[string]string{"tls.crt": "cert", "tls.key": "key"}},
+			},
+		},
+		{
+			name: "merge",
+			dir:  "merge",
+			res: []Secret{
+				{Name: "prod-token", Namespace: "prod", Labels: map[string]string{"env": "prod"}, Data: map[string]string{"TOKEN": "xyz"}},
+				{Name: "prod-app-9fhdf98575", Namespace: "prod", Labels: map[string]string{"app": "web", "env": "prod"}, Immutable: true, Data: map[string]string{"DB_HOST": "db.prod", "DB_PASS": `"s3cr3t"`, "MODE": "debug", "PASSWORD": "abc$DEF#x 'y' "}},
+				{Name: "prod-tls", Namespace: "prod", Labels: map[string]string{"app": "web", "env": "prod"}, Type: SecretTypeTLS, Data: map[string]string{"tls.crt": "cert", "tls.key": "key"}},
+			},
+		},
+		{
+			name: "invalid-env",
+			dir:  "invalid-env",
+			err:  errors.New(`secretGenerator "app": env file "app.env": line 2: invalid key "export B": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+		{
+			name: "create-existing",
+			dir:  "create-existing",
+			err:  errors.New(`secretGenerator "app": secret already exists, so the behavior must be merge or replace`),
+		},
+		{
+			name: "merge-missing",
+			dir:  "merge-missing",
+			err:  errors.New(`secretGenerator "app": secret does not exist, so it cannot be merged`),
+		},
+		{
+			name: "outside",
+			dir:  "outside",
+			err:  errors.New(`secretGenerator "app": file "../base/app.env" is not in or below the directory of the kustomization`),
+		},
+		{
+			name: "remote",
+			dir:  "remote",
+			err:  errors.New(`resource "https://github.com/example/config": remote resources are not supported`),
+		},
+		{
+			name: "invalid-literal",
+			dir:  "invalid-literal",
+			err:  errors.New(`secretGenerator "app": invalid literal "=b", expected key=value`),
+		},
+		{
+			name: "cycle",
+			dir:  "cycle",
+			err:  errors.New(`resource ".": cycle detected at "` + filepath.Join(dir, "cycle") + `"`),
+		},
+		{
+			name: "no-kustomization",
+			dir:  "empty",
+			err:  errors.New(`no kustomization.yaml, kustomization.yml or Kustomization found in "` + filepath.Join(dir, "empty") + `"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := KustomizeSecrets(filepath.Join(dir, test.dir), DecryptionKeys{})
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestSecretGeneratorStanza tests the SecretGeneratorStanza function
+func TestSecretGeneratorStanza(t *testing.T) {
+	t.Parallel()
+	expected := `secretGenerator:
+- name: app
+  behavior: merge
+  envs:
+  - app.env
+  type: Opaque
+  options:
+    labels:
+      app: web
+    disableNameSuffixHash: true
+`
+	res, err := SecretGeneratorStanza(SecretGeneratorArgs{
+		Name:     "app",
+		Behavior: GeneratorBehaviorMerge,
+		Envs:     []string{"app.env"},
+		Type:     SecretTypeOpaque,
+		Options:  &GeneratorOptions{Labels: map[string]string{"app": "web"}, DisableNameSuffixHash: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != expected {
+		t.Fatalf("expected response to be %q but got %q", expected, res)
+	}
+}
+
+// TestLiteralSources tests the LiteralSources function
+func TestLiteralSources(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		data map[string]string
+		res  []string
+		err  error
+	}{
+		{
+			name: "plain",
+			data: map[string]string{"B": "x=y", "A": "it's"},
+			res:  []string{"A=it's", "B=x=y"},
+		},
+		{
+			name: "quoted",
+			data: map[string]string{"A": `"x"`, "B": "'y'"},
+			res:  []string{`A=""x""`, `B="'y'"`},
+		},
+		{
+			name: "binary",
+			data: map[string]string{"A": "\xff"},
+			err:  errors.New(`value of key "A" is not valid UTF-8 and cannot be written to a literal`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := LiteralSources(test.data)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+				for i, literal := range res {
+					if k, v := literal[:1], unquoteLiteral(literal[2:]); test.data[k] != v {
+						t.Fatalf("expected literal %d to be read back as %q but got %q", i, test.data[k], v)
+					}
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestParseKustomizeEnv tests the parseKustomizeEnv function
+func TestParseKustomizeEnv(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		res   map[string]string
+		err   error
+	}{
+		{
+			name:  "verbatim",
+			input: "\xef\xbb\xbfA=\"b\" # c\r\n\n  # comment\n\tB=$A=d\n",
+			res:   map[string]string{"A": `"b" # c`, "B": "$A=d"},
+		},
+		{
+			name:  "bare-key",
+			input: "HOME\nA=\n",
+			res:   map[string]string{"HOME": "", "A": ""},
+		},
+		{
+			name:  "leading-digit",
+			input: "1KEY=a\n",
+			res:   map[string]string{"1KEY": "a"},
+		},
+		{
+			name:  "invalid-key",
+			input: "A=b\nA B=c\n",
+			err:   errors.New(`line 2: invalid key "A B": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+		{
+			name:  "duplicate",
+			input: "A=b\nA=c\n",
+			err:   errors.New(`line 2: duplicate key "A"`),
+		},
+		{
+			name:  "invalid-utf8",
+			input: "A=\xff\n",
+			err:   errors.New("line 1 is not valid UTF-8"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := parseKustomizeEnv([]byte(test.input))
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestKustomizeEnvMismatches tests the KustomizeEnvMismatches function
+func TestKustomizeEnvMismatches(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		res   []string
+		err   error
+	}{
+		{
+			name:  "mismatches",
+			input: "# comment\nPLAIN=value\nQUOTED=\"value\"\nCOMMENTED=value # comment\nVARIABLE=$PLAIN\n  INDENTED=value\n",
+			res:   []string{"COMMENTED", "QUOTED", "VARIABLE"},
+		},
+		{
+			name:  "none",
+			input: "PLAIN=value\nEQUALS=a=b\n",
+		},
+		{
+			name:  "export",
+			input: "PLAIN=value\nexport EXPORTED=value\n",
+			err:   errors.New(`kustomize cannot read the input: line 2: invalid key "export EXPORTED": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := KustomizeEnvMismatches([]byte(test.input))
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
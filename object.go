@@ -0,0 +1,292 @@
+package k8shhh
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TypeMeta is the api version and the kind of a kubernetes object
+type TypeMeta struct {
+	APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty" yaml:"kind,omitempty"`
+}
+
+// ObjectMeta is the metadata of a kubernetes object
+type ObjectMeta struct {
+	Name            string            `json:"name" yaml:"name"`
+	Namespace       string            `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations     map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences,omitempty" yaml:"ownerReferences,omitempty"`
+}
+
+// OwnerReference identifies the object owning a kubernetes object, which is
+// garbage collected along with its owner
+type OwnerReference struct {
+	APIVersion         string `json:"apiVersion" yaml:"apiVersion"`
+	Kind               string `json:"kind" yaml:"kind"`
+	Name               string `json:"name" yaml:"name"`
+	UID                string `json:"uid" yaml:"uid"`
+	Controller         *bool  `json:"controller,omitempty" yaml:"controller,omitempty"`
+	BlockOwnerDeletion *bool  `json:"blockOwnerDeletion,omitempty" yaml:"blockOwnerDeletion,omitempty"`
+}
+
+// SecretObject is a kubernetes secret in the shape of the v1 Secret of the
+// API server. The values of Data are raw bytes, which are base64 encoded in
+// manifests, while the values of StringData are written as is. A
+// SecretObject marshals to and unmarshals from json and yaml manifests.
+type SecretObject struct {
+	TypeMeta
+	ObjectMeta
+	Immutable  *bool
+	Data       map[string][]byte
+	StringData map[string]string
+	Type       SecretType
+}
+
+// NewSecretObject returns the object of the secret, with its values written
+// to Data or StringData according to the given mode
+func NewSecretObject(secret Secret, mode DataMode) (SecretObject, error) {
+	obj := SecretObject{
+		TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: ObjectMeta{
+			Name:        secret.Name,
+			Namespace:   secret.Namespace,
+			Labels:      secret.Labels,
+			Annotations: secret.Annotations,
+		},
+		Type: secret.Type,
+	}
+	if secret.Immutable {
+		immutable := true
+		obj.Immutable = &immutable
+	}
+	data := make(map[string][]byte)
+	switch mode {
+	case DataModeData, "":
+		obj.Data = data
+	case DataModeStringData, DataModeHybrid:
+		obj.StringData = make(map[string]string)
+	default:
+		return SecretObject{}, fmt.Errorf("unknown data mode %q", mode)
+	}
+	for _, k := range sortedKeys(secret.Data) {
+		v := secret.Data[k]
+		if mode == DataModeStringData {
+			if !utf8.ValidString(v) {
+				return SecretObject{}, fmt.Errorf("value of key %q is not valid UTF-8 and cannot be written to stringData", k)
+			}
+			obj.StringData[k] = v
+		} else if mode == DataModeHybrid && isPrintable(v) {
+			obj.StringData[k] = v
+		} else {
+			data[k] = []byte(v)
+		}
+	}
+	if len(data) > 0 {
+		obj.Data = data
+	}
+	return obj, nil
+}
+
+// DecodeObjects decodes the input based on the given decoder in the same way
+// as DecodeSecrets, and returns every secret it holds as an object, whose
+// data and stringData are kept apart
+func DecodeObjects(input io.Reader, decoder Decoder) ([]SecretObject, []string, error) {
+	return decodeObjects(input, decoder, nil)
+}
+
+// Secret returns the secret of the object, whose data is the data of the
+// object merged with its stringData, which takes precedence. The data is nil
+// if the object has neither.
+func (o SecretObject) Secret() Secret {
+	secret, _ := o.secret()
+	return secret
+}
+
+// Values returns the raw bytes of the values of the object, which are its
+// data merged with its stringData, which takes precedence, in the same way as
+// the API server does. It returns nil if the object has neither.
+func (o SecretObject) Values() map[string][]byte {
+	if o.Data == nil && o.StringData == nil {
+		return nil
+	}
+	values := make(map[string][]byte, len(o.Data)+len(o.StringData))
+	for k, v := range o.Data {
+		values[k] = v
+	}
+	for k, v := range o.StringData {
+		values[k] = []byte(v)
+	}
+	return values
+}
+
+// secret returns the secret of the object along with the keys of the data
+// overridden by the stringData
+func (o SecretObject) secret() (Secret, []string) {
+	secret := Secret{
+		Name:        o.Name,
+		Namespace:   o.Namespace,
+		Labels:      o.Labels,
+		Annotations: o.Annotations,
+		Type:        o.Type,
+		Immutable:   o.Immutable != nil && *o.Immutable,
+	}
+	if o.Data != nil {
+		secret.Data = make(map[string]string, len(o.Data))
+		for k, v := range o.Data {
+			secret.Data[k] = string(v)
+		}
+	}
+	var overridden []string
+	for _, k := range sortedKeys(o.StringData) {
+		if secret.Data == nil {
+			secret.Data = make(map[string]string)
+		}
+		if _, ok := secret.Data[k]; ok {
+			overridden = append(overridden, k)
+		}
+		secret.Data[k] = o.StringData[k]
+	}
+	return secret, overridden
+}
+
+// MarshalJSON marshals the object to a json manifest
+func (o SecretObject) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.template())
+}
+
+// MarshalYAML marshals the object to a yaml manifest
+func (o SecretObject) MarshalYAML() (interface{}, error) {
+	return o.template(), nil
+}
+
+// UnmarshalJSON unmarshals the object from a json manifest
+func (o *SecretObject) UnmarshalJSON(b []byte) error {
+	var object map[string]interface{}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return err
+	}
+	obj, err := decodeObject(object)
+	if err != nil {
+		return err
+	}
+	*o = obj
+	return nil
+}
+
+// UnmarshalYAML unmarshals the object from a yaml manifest
+func (o *SecretObject) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var object map[interface{}]interface{}
+	if err := unmarshal(&object); err != nil {
+		return err
+	}
+	obj, err := decodeObject(convertKeysToStrings(object))
+	if err != nil {
+		return err
+	}
+	*o = obj
+	return nil
+}
+
+// template returns the manifest of the object, with the values of its data
+// base64 encoded. The api version, the kind and the type default to those of
+// an Opaque v1 Secret.
+func (o SecretObject) template() template {
+	tmpl := template{
+		APIVersion: o.APIVersion,
+		Immutable:  o.Immutable,
+		Kind:       o.Kind,
+		Metadata:   o.ObjectMeta,
+		StringData: o.StringData,
+		Type:       string(o.Type),
+	}
+	if tmpl.APIVersion == "" {
+		tmpl.APIVersion = "v1"
+	}
+	if tmpl.Kind == "" {
+		tmpl.Kind = "Secret"
+	}
+	if tmpl.Type == "" {
+		tmpl.Type = string(SecretTypeOpaque)
+	}
+	if o.Data != nil {
+		data := make(map[string]string, len(o.Data))
+		for k, v := range o.Data {
+			data[k] = base64.StdEncoding.EncodeToString(v)
+		}
+		tmpl.Data = &data
+	}
+	return tmpl
+}
+
+// decodeObject reads the type, the metadata, the immutability and the data
+// of the given secret object, base64 decoding the values of its data
+func decodeObject(object map[string]interface{}) (SecretObject, error) {
+	obj := SecretObject{
+		TypeMeta: TypeMeta{
+			APIVersion: stringValue(object["apiVersion"]),
+			Kind:       stringValue(object["kind"]),
+		},
+		Type: SecretType(stringValue(object["type"])),
+	}
+
+	if object["metadata"] != nil {
+		metadata, err := toStringMap(object["metadata"])
+		if err != nil {
+			return SecretObject{}, err
+		}
+		obj.Name = stringValue(metadata["name"])
+		obj.Namespace = stringValue(metadata["namespace"])
+		if obj.Labels, err = stringMapValue(metadata["labels"]); err != nil {
+			return SecretObject{}, err
+		}
+		if obj.Annotations, err = stringMapValue(metadata["annotations"]); err != nil {
+			return SecretObject{}, err
+		}
+		if refs := metadata["ownerReferences"]; refs != nil {
+			// the references are either json or yaml values, which both
+			// marshal to yaml
+			b, err := yaml.Marshal(refs)
+			if err != nil {
+				return SecretObject{}, err
+			}
+			if err := yaml.Unmarshal(b, &obj.OwnerReferences); err != nil {
+				return SecretObject{}, fmt.Errorf("ownerReferences: %v", err)
+			}
+		}
+	}
+
+	switch immutable := object["immutable"].(type) {
+	case nil:
+	case bool:
+		obj.Immutable = &immutable
+	default:
+		return SecretObject{}, fmt.Errorf("unexpected type: %T", immutable)
+	}
+
+	data, err := stringMapValue(object["data"])
+	if err != nil {
+		return SecretObject{}, err
+	}
+	if data != nil {
+		obj.Data = make(map[string][]byte, len(data))
+		for k, v := range data {
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return SecretObject{}, err
+			}
+			obj.Data[k] = b
+		}
+	}
+
+	if obj.StringData, err = stringMapValue(object["stringData"]); err != nil {
+		return SecretObject{}, err
+	}
+	return obj, nil
+}
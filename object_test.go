@@ -0,0 +1,256 @@
+package k8shhh
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const successObjectYAML = `apiVersion: v1
+data:
+  a: Yg==
+  bin: /w==
+immutable: true
+kind: Secret
+metadata:
+  name: object
+  namespace: production
+  labels:
+    app: web
+  ownerReferences:
+  - apiVersion: apps/v1
+    kind: Deployment
+    name: web
+    uid: 6b8d7d6e-5f0a-4a8e-9d0e-6f1e2a3b4c5d
+    controller: true
+stringData:
+  c: d
+type: Opaque
+`
+
+const successObjectJSON = `{"apiVersion":"v1","data":{"a":"Yg==","bin":"/w=="},"immutable":true,"kind":"Secret","metadata":{"name":"object","namespace":"production","labels":{"app":"web"},"ownerReferences":[{"apiVersion":"apps/v1","kind":"Deployment","name":"web","uid":"6b8d7d6e-5f0a-4a8e-9d0e-6f1e2a3b4c5d","controller":true}]},"stringData":{"c":"d"},"type":"Opaque"}`
+
+// successObject returns the object of successObjectYAML and
+// successObjectJSON
+func successObject() SecretObject {
+	immutable, controller := true, true
+	return SecretObject{
+		TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: ObjectMeta{
+			Name:      "object",
+			Namespace: "production",
+			Labels:    map[string]string{"app": "web"},
+			OwnerReferences: []OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "web",
+				UID:        "6b8d7d6e-5f0a-4a8e-9d0e-6f1e2a3b4c5d",
+				Controller: &controller,
+			}},
+		},
+		Immutable:  &immutable,
+		Data:       map[string][]byte{"a": []byte("b"), "bin": {0xff}},
+		StringData: map[string]string{"c": "d"},
+		Type:       SecretTypeOpaque,
+	}
+}
+
+// TestNewSecretObject tests the NewSecretObject function
+func TestNewSecretObject(t *testing.T) {
+	t.Parallel()
+	secret := Secret{Name: "object", Type: SecretTypeOpaque, Immutable: true, Data: map[string]string{"a": "b", "bin": "\xff"}}
+	immutable := true
+	tests := []struct {
+		name string
+		mode DataMode
+		res  SecretObject
+		err  error
+	}{
+		{
+			name: "data",
+			mode: DataModeData,
+			res: SecretObject{
+				TypeMeta:   TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				ObjectMeta: ObjectMeta{Name: "object"},
+				Immutable:  &immutable,
+				Data:       map[string][]byte{"a": []byte("b"), "bin": {0xff}},
+				Type:       SecretTypeOpaque,
+			},
+		},
+		{
+			name: "hybrid",
+			mode: DataModeHybrid,
+			res: SecretObject{
+				TypeMeta:   TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				ObjectMeta: ObjectMeta{Name: "object"},
+				Immutable:  &immutable,
+				Data:       map[string][]byte{"bin": {0xff}},
+				StringData: map[string]string{"a": "b"},
+				Type:       SecretTypeOpaque,
+			},
+		},
+		{
+			name: "string-data",
+			mode: DataModeStringData,
+			err:  errors.New(`value of key "bin" is not valid UTF-8 and cannot be written to stringData`),
+		},
+		{
+			name: "unknown",
+			mode: "base32",
+			err:  errors.New(`unknown data mode "base32"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, err := NewSecretObject(secret, test.mode)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestSecretObjectMarshal tests the marshaling of SecretObject to json and
+// yaml manifests, and back
+func TestSecretObjectMarshal(t *testing.T) {
+	t.Parallel()
+	obj := successObject()
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != successObjectJSON {
+		t.Fatalf("expected json to be %q but got %q", successObjectJSON, b)
+	}
+	b, err = yaml.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != successObjectYAML {
+		t.Fatalf("expected yaml to be %q but got %q", successObjectYAML, b)
+	}
+
+	var fromJSON, fromYAML SecretObject
+	if err := json.Unmarshal([]byte(successObjectJSON), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, obj) {
+		t.Fatalf("expected object to be %+v but got %+v", obj, fromJSON)
+	}
+	if err := yaml.Unmarshal([]byte(successObjectYAML), &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, obj) {
+		t.Fatalf("expected object to be %+v but got %+v", obj, fromYAML)
+	}
+}
+
+// TestDecodeObjects tests the DecodeObjects function
+func TestDecodeObjects(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		input   string
+		decoder Decoder
+		res     []SecretObject
+		err     error
+	}{
+		{
+			name:    "yaml",
+			input:   successObjectYAML,
+			decoder: DecodeYAML,
+			res:     []SecretObject{successObject()},
+		},
+		{
+			name:    "json",
+			input:   successObjectJSON,
+			decoder: DecodeJSON,
+			res:     []SecretObject{successObject()},
+		},
+		{
+			name:    "immutable-invalid",
+			input:   "kind: Secret\nimmutable: yes please\n",
+			decoder: DecodeYAML,
+			err:     errors.New("unexpected type: string"),
+		},
+		{
+			name:    "data-invalid",
+			input:   "kind: Secret\ndata:\n  a: b\n",
+			decoder: DecodeYAML,
+			err:     errors.New("illegal base64 data at input byte 0"),
+		},
+		{
+			name:    "no-secret",
+			input:   errorDecodeYAMLTestNoSecret,
+			decoder: DecodeYAML,
+			err:     errors.New("no secret found in input"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, _, err := DecodeObjects(strings.NewReader(test.input), test.decoder)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestSecretObjectSecret tests the Secret method of SecretObject
+func TestSecretObjectSecret(t *testing.T) {
+	t.Parallel()
+	obj := successObject()
+	obj.StringData["a"] = "override"
+	expected := Secret{
+		Name:      "object",
+		Namespace: "production",
+		Labels:    map[string]string{"app": "web"},
+		Type:      SecretTypeOpaque,
+		Immutable: true,
+		Data:      map[string]string{"a": "override", "bin": "\xff", "c": "d"},
+	}
+	if res := obj.Secret(); !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected response to be %+v but got %+v", expected, res)
+	}
+}
+
+// TestSecretObjectValues tests the Values method of SecretObject
+func TestSecretObjectValues(t *testing.T) {
+	t.Parallel()
+	obj := successObject()
+	obj.StringData["a"] = "override"
+	expected := map[string][]byte{"a": []byte("override"), "bin": {0xff}, "c": []byte("d")}
+	if res := obj.Values(); !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected response to be %q but got %q", expected, res)
+	}
+	if res := (SecretObject{}).Values(); res != nil {
+		t.Fatalf("expected response to be nil but got %q", res)
+	}
+}
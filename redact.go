@@ -0,0 +1,94 @@
+package k8shhh
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// jwtRegexp matches the three base64url encoded segments of a JSON Web Token
+var jwtRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+
+// DecodeRedacted decodes the input based on the given decoder, and renders
+// the secrets in the same way as FormatRedactedSecrets, without revealing
+// any value
+func DecodeRedacted(input io.Reader, decoder Decoder) ([]byte, error) {
+	secrets, _, err := DecodeSecrets(input, decoder)
+	if err != nil {
+		return []byte{}, err
+	}
+	return FormatRedactedSecrets(secrets), nil
+}
+
+// FormatRedactedSecrets renders the keys of the secrets with the kind of
+// their value, a prefix of its SHA-256 hash and its length, but never the
+// value itself. When there are several secrets, the keys of each secret are
+// grouped under a header naming it.
+func FormatRedactedSecrets(secrets []Secret) []byte {
+	groups := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		lines := make([]string, 0, len(secret.Data)+1)
+		if len(secrets) > 1 {
+			lines = append(lines, fmt.Sprintf("# %s", qualifiedSecretName(secret)))
+		}
+		for _, k := range sortedKeys(secret.Data) {
+			v := secret.Data[k]
+			lines = append(lines, fmt.Sprintf("%s: %s, %s", k, DetectValueKind(v), maskValue(v)))
+		}
+		groups = append(groups, strings.Join(lines, "\n"))
+	}
+	return []byte(strings.Join(groups, "\n\n"))
+}
+
+// DetectValueKind returns the kind of the value, which is one of "empty",
+// "binary", "PEM <block type>" (e.g. "PEM CERTIFICATE"), "JWT", "URL with
+// password", "URL", "JSON" or "text"
+func DetectValueKind(value string) string {
+	trimmed := strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return "empty"
+	case !isPrintable(value):
+		return "binary"
+	case strings.HasPrefix(trimmed, "-----BEGIN "):
+		if block, _ := pem.Decode([]byte(trimmed)); block != nil {
+			return "PEM " + block.Type
+		}
+	case isJWT(trimmed):
+		return "JWT"
+	case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["):
+		if json.Valid([]byte(trimmed)) {
+			return "JSON"
+		}
+	}
+	if u, err := url.Parse(trimmed); err == nil && u.Scheme != "" && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			return "URL with password"
+		}
+		return "URL"
+	}
+	return "text"
+}
+
+// isJWT checks whether the value is a JSON Web Token, whose header is a JSON
+// object naming its algorithm
+func isJWT(value string) bool {
+	if !jwtRegexp.MatchString(value) {
+		return false
+	}
+	header, err := base64.RawURLEncoding.DecodeString(value[:strings.Index(value, ".")])
+	if err != nil {
+		return false
+	}
+	var h map[string]interface{}
+	if err := json.Unmarshal(header, &h); err != nil {
+		return false
+	}
+	_, ok := h["alg"]
+	return ok
+}
@@ -0,0 +1,328 @@
+package k8shhh
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The annotations widening the scope of a sealed secret, which is otherwise
+// bound to its name and namespace
+const (
+	SealedSecretNamespaceWideAnnotation = "sealedsecrets.bitnami.com/namespace-wide"
+	SealedSecretClusterWideAnnotation   = "sealedsecrets.bitnami.com/cluster-wide"
+)
+
+// sessionKeyBytes is the size of the AES-256 key encrypting each value of a
+// sealed secret
+const sessionKeyBytes = 32
+
+// sealedTemplate is the template struct of a Bitnami SealedSecret for both
+// json and yaml encoding
+type sealedTemplate struct {
+	APIVersion string     `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string     `json:"kind" yaml:"kind"`
+	Metadata   ObjectMeta `json:"metadata" yaml:"metadata"`
+	Spec       sealedSpec `json:"spec" yaml:"spec"`
+}
+
+// sealedSpec is the spec of a SealedSecret, holding the encrypted values and
+// the template of the secret they are unsealed to
+type sealedSpec struct {
+	EncryptedData map[string]string `json:"encryptedData" yaml:"encryptedData"`
+	Template      secretTemplate    `json:"template" yaml:"template"`
+}
+
+// secretTemplate is the metadata and the type of the secret a SealedSecret
+// is unsealed to
+type secretTemplate struct {
+	Metadata ObjectMeta `json:"metadata" yaml:"metadata"`
+	Type     string     `json:"type" yaml:"type"`
+}
+
+// SealedJSONEncoder returns an encoder which seals the secret with the given
+// public key, and outputs it as a SealedSecret to a json format
+func SealedJSONEncoder(key *rsa.PublicKey) Encoder {
+	return jsonEncoder(sealedTemplater(key))
+}
+
+// SealedYAMLEncoder returns an encoder which seals the secret with the given
+// public key, and outputs it as a SealedSecret to a yaml format
+func SealedYAMLEncoder(key *rsa.PublicKey) Encoder {
+	return yamlEncoder(sealedTemplater(key))
+}
+
+// SealedJSONListEncoder returns a list encoder which seals the secrets with
+// the given public key, and outputs them to a json format as the items of a
+// v1 List
+func SealedJSONListEncoder(key *rsa.PublicKey) ListEncoder {
+	return jsonListEncoder(sealedTemplater(key))
+}
+
+// SealedYAMLListEncoder returns a list encoder which seals the secrets with
+// the given public key, and outputs them to a yaml format as a stream of
+// documents separated by "---"
+func SealedYAMLListEncoder(key *rsa.PublicKey) ListEncoder {
+	return yamlListEncoder(sealedTemplater(key))
+}
+
+// ParsePublicKey parses the PEM encoded certificate of the sealed secrets
+// controller, as printed by `kubeseal --fetch-cert`, or its RSA public key
+func ParsePublicKey(input []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(input)
+	if block == nil {
+		return nil, errors.New("no PEM data found in public key")
+	}
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	case "PUBLIC KEY":
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	case "RSA PUBLIC KEY":
+		k, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = k
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q in public key", block.Type)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected public key type: %T", key)
+	}
+	return rsaKey, nil
+}
+
+// ParsePrivateKey parses the PEM encoded RSA private key of the sealed
+// secrets controller
+func ParsePrivateKey(input []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(input)
+	if block == nil {
+		return nil, errors.New("no PEM data found in private key")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unexpected private key type: %T", key)
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q in private key", block.Type)
+	}
+}
+
+// sealedTemplater returns a templater which puts the secret to a
+// SealedSecret object, with each value encrypted with the given public key
+// and bound to the name and namespace of the secret. A cluster-wide secret is
+// bound to neither, so it needs no namespace.
+func sealedTemplater(key *rsa.PublicKey) templater {
+	return func(secret Secret) (interface{}, error) {
+		if secret.Namespace == "" && secret.Annotations[SealedSecretClusterWideAnnotation] != "true" {
+			return nil, errors.New("namespace is required to seal a secret which is not cluster-wide")
+		}
+		// the name is bound to the sealed values, so it is suffixed first
+		secret = withHashSuffix(secret)
+		tmpl, err := generateTemplate(secret, DataModeData)
+		if err != nil {
+			return nil, err
+		}
+		label := sealingLabel(secret.Name, secret.Namespace, secret.Annotations)
+		sealed := sealedTemplate{
+			APIVersion: "bitnami.com/v1alpha1",
+			Kind:       "SealedSecret",
+			Metadata: ObjectMeta{
+				Name:        secret.Name,
+				Namespace:   secret.Namespace,
+				Annotations: scopeAnnotations(secret.Annotations),
+			},
+			Spec: sealedSpec{
+				EncryptedData: make(map[string]string),
+				Template: secretTemplate{
+					Metadata: tmpl.Metadata,
+					Type:     tmpl.Type,
+				},
+			},
+		}
+		for _, k := range sortedKeys(secret.Data) {
+			ciphertext, err := hybridEncrypt(rand.Reader, key, []byte(secret.Data[k]), label)
+			if err != nil {
+				return nil, fmt.Errorf("sealing key %q: %v", k, err)
+			}
+			sealed.Spec.EncryptedData[k] = base64.StdEncoding.EncodeToString(ciphertext)
+		}
+		return sealed, nil
+	}
+}
+
+// decodeSealedSecret reads the metadata and the type of the given
+// SealedSecret object, and decrypts its values with the given private key
+func decodeSealedSecret(object map[string]interface{}, key *rsa.PrivateKey) (Secret, error) {
+	var secret Secret
+	if object["metadata"] != nil {
+		metadata, err := toStringMap(object["metadata"])
+		if err != nil {
+			return Secret{}, err
+		}
+		secret.Name = stringValue(metadata["name"])
+		secret.Namespace = stringValue(metadata["namespace"])
+		if secret.Annotations, err = stringMapValue(metadata["annotations"]); err != nil {
+			return Secret{}, err
+		}
+	}
+	label := sealingLabel(secret.Name, secret.Namespace, secret.Annotations)
+
+	spec, err := toStringMap(object["spec"])
+	if err != nil {
+		return Secret{}, err
+	}
+	if spec["template"] != nil {
+		template, err := toStringMap(spec["template"])
+		if err != nil {
+			return Secret{}, err
+		}
+		// the template only carries the metadata of the unsealed secret,
+		// which keeps the name and namespace of the sealed one
+		templateSecret, _, err := decodeSecret(map[string]interface{}{
+			"metadata": template["metadata"],
+			"type":     template["type"],
+		})
+		if err != nil {
+			return Secret{}, err
+		}
+		secret.Labels = templateSecret.Labels
+		secret.Annotations = templateSecret.Annotations
+		secret.Type = templateSecret.Type
+	}
+
+	encrypted, err := stringMapValue(spec["encryptedData"])
+	if err != nil {
+		return Secret{}, err
+	}
+	for _, k := range sortedKeys(encrypted) {
+		ciphertext, err := base64.StdEncoding.DecodeString(encrypted[k])
+		if err != nil {
+			return Secret{}, fmt.Errorf("unsealing key %q: %v", k, err)
+		}
+		plaintext, err := hybridDecrypt(key, ciphertext, label)
+		if err != nil {
+			return Secret{}, fmt.Errorf("unsealing key %q: %v", k, err)
+		}
+		if secret.Data == nil {
+			secret.Data = make(map[string]string)
+		}
+		secret.Data[k] = string(plaintext)
+	}
+	return secret, nil
+}
+
+// sealingLabel returns the label binding the encrypted values to the scope
+// of the sealed secret, which is its namespace and name unless widened by an
+// annotation
+func sealingLabel(name, namespace string, annotations map[string]string) []byte {
+	switch {
+	case annotations[SealedSecretClusterWideAnnotation] == "true":
+		return []byte{}
+	case annotations[SealedSecretNamespaceWideAnnotation] == "true":
+		return []byte(namespace)
+	default:
+		return []byte(namespace + "/" + name)
+	}
+}
+
+// scopeAnnotations returns the scope annotations among the given ones, which
+// are kept on the SealedSecret itself
+func scopeAnnotations(annotations map[string]string) map[string]string {
+	var res map[string]string
+	for _, k := range []string{SealedSecretNamespaceWideAnnotation, SealedSecretClusterWideAnnotation} {
+		if v, ok := annotations[k]; ok {
+			if res == nil {
+				res = make(map[string]string)
+			}
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// hybridEncrypt encrypts the plaintext with a random AES-256-GCM session key,
+// itself encrypted with RSA-OAEP under the given public key and label. The
+// output is the length of the encrypted session key as a big endian uint16,
+// followed by the encrypted session key and the AES-GCM ciphertext, as done
+// by the sealed secrets controller.
+func hybridEncrypt(rnd io.Reader, key *rsa.PublicKey, plaintext, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, sessionKeyBytes)
+	if _, err := io.ReadFull(rnd, sessionKey); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), rnd, key, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, 2, 2+len(rsaCiphertext)+len(plaintext)+aead.Overhead())
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(rsaCiphertext)))
+	ciphertext = append(ciphertext, rsaCiphertext...)
+	// the session key is never reused, so a zero nonce is safe
+	zeroNonce := make([]byte, aead.NonceSize())
+	return aead.Seal(ciphertext, zeroNonce, plaintext, nil), nil
+}
+
+// hybridDecrypt decrypts the output of hybridEncrypt with the given private
+// key and label
+func hybridDecrypt(key *rsa.PrivateKey, ciphertext, label []byte) ([]byte, error) {
+	if len(ciphertext) < 2 {
+		return nil, errors.New("ciphertext too short")
+	}
+	rsaLen := int(binary.BigEndian.Uint16(ciphertext))
+	if len(ciphertext) < 2+rsaLen {
+		return nil, errors.New("ciphertext too short")
+	}
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, ciphertext[2:2+rsaLen], label)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	zeroNonce := make([]byte, aead.NonceSize())
+	return aead.Open(nil, zeroNonce, ciphertext[2+rsaLen:], nil)
+}
+
+// newAEAD returns the AES-GCM cipher of the given session key
+func newAEAD(sessionKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
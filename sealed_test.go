@@ -0,0 +1,224 @@
+package k8shhh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSealedEncoder tests the SealedYAMLEncoder and SealedJSONEncoder
+// functions along with DecodeSealedSecrets
+func TestSealedEncoder(t *testing.T) {
+	t.Parallel()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		encoder   Encoder
+		decoder   Decoder
+		secret    Secret
+		key       *rsa.PrivateKey
+		namespace string
+		res       []Secret
+		warnings  []string
+		err       error
+	}{
+		{
+			name:    "yaml-sealed",
+			encoder: SealedYAMLEncoder(&key.PublicKey),
+			decoder: DecodeYAML,
+			secret:  Secret{Name: "yaml-sealed", Namespace: "production", Labels: map[string]string{"app": "web"}, Data: map[string]string{"a": "b", "c": "\xff"}},
+			key:     key,
+			res: []Secret{
+				{Name: "yaml-sealed", Namespace: "production", Labels: map[string]string{"app": "web"}, Type: SecretTypeOpaque, Data: map[string]string{"a": "b", "c": "\xff"}},
+			},
+		},
+		{
+			name:    "json-sealed",
+			encoder: SealedJSONEncoder(&key.PublicKey),
+			decoder: DecodeJSON,
+			secret:  Secret{Name: "json-sealed", Namespace: "production", Type: SecretTypeBasicAuth, Data: map[string]string{"username": "admin"}},
+			key:     key,
+			res: []Secret{
+				{Name: "json-sealed", Namespace: "production", Type: SecretTypeBasicAuth, Data: map[string]string{"username": "admin"}},
+			},
+		},
+		{
+			name:     "no-key",
+			encoder:  SealedYAMLEncoder(&key.PublicKey),
+			decoder:  DecodeYAML,
+			secret:   Secret{Name: "no-key", Namespace: "production", Data: map[string]string{"a": "b"}},
+			warnings: []string{`skipping sealed secret "production/no-key", as no private key is given`},
+			err:      errors.New("no secret found in input"),
+		},
+		{
+			name:    "wrong-key",
+			encoder: SealedYAMLEncoder(&key.PublicKey),
+			decoder: DecodeYAML,
+			secret:  Secret{Name: "wrong-key", Namespace: "production", Data: map[string]string{"a": "b"}},
+			key:     other,
+			err:     errors.New(`sealed secret "production/wrong-key": unsealing key "a": crypto/rsa: decryption error`),
+		},
+		{
+			name:      "moved-namespace",
+			encoder:   SealedYAMLEncoder(&key.PublicKey),
+			decoder:   DecodeYAML,
+			secret:    Secret{Name: "moved-namespace", Namespace: "production", Data: map[string]string{"a": "b"}},
+			key:       key,
+			namespace: "staging",
+			err:       errors.New(`sealed secret "staging/moved-namespace": unsealing key "a": crypto/rsa: decryption error`),
+		},
+		{
+			name:      "namespace-wide",
+			encoder:   SealedYAMLEncoder(&key.PublicKey),
+			decoder:   DecodeYAML,
+			secret:    Secret{Name: "namespace-wide", Namespace: "production", Annotations: map[string]string{SealedSecretNamespaceWideAnnotation: "true"}, Data: map[string]string{"a": "b"}},
+			key:       key,
+			namespace: "production",
+			res: []Secret{
+				{Name: "namespace-wide", Namespace: "production", Annotations: map[string]string{SealedSecretNamespaceWideAnnotation: "true"}, Type: SecretTypeOpaque, Data: map[string]string{"a": "b"}},
+			},
+		},
+		{
+			name:    "cluster-wide",
+			encoder: SealedYAMLEncoder(&key.PublicKey),
+			decoder: DecodeYAML,
+			secret:  Secret{Name: "cluster-wide", Annotations: map[string]string{SealedSecretClusterWideAnnotation: "true"}, Data: map[string]string{"a": "b"}},
+			key:     key,
+			res: []Secret{
+				{Name: "cluster-wide", Annotations: map[string]string{SealedSecretClusterWideAnnotation: "true"}, Type: SecretTypeOpaque, Data: map[string]string{"a": "b"}},
+			},
+		},
+		{
+			name:    "no-namespace",
+			encoder: SealedYAMLEncoder(&key.PublicKey),
+			secret:  Secret{Name: "no-namespace", Data: map[string]string{"a": "b"}},
+			err:     errors.New("namespace is required to seal a secret which is not cluster-wide"),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.encoder(test.secret)
+			if err == nil {
+				if test.decoder == nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				if test.namespace != "" {
+					b = bytes.Replace(b, []byte("namespace: production"), []byte("namespace: "+test.namespace), -1)
+				}
+				var warnings []string
+				var res []Secret
+				res, warnings, err = DecodeSealedSecrets(bytes.NewReader(b), test.decoder, test.key)
+				if !reflect.DeepEqual(res, test.res) {
+					t.Fatalf("expected response to be %+v but got %+v", test.res, res)
+				}
+				if !reflect.DeepEqual(warnings, test.warnings) {
+					t.Fatalf("expected warnings to be %q but got %q", test.warnings, warnings)
+				}
+			}
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestSealedYAMLListEncoder tests the SealedYAMLListEncoder function
+func TestSealedYAMLListEncoder(t *testing.T) {
+	t.Parallel()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := []Secret{
+		{Name: "a", Namespace: "production", Type: SecretTypeOpaque, Data: map[string]string{"a": "b"}},
+		{Name: "b", Namespace: "production", Type: SecretTypeOpaque, Data: map[string]string{"c": "d"}},
+	}
+	b, err := SealedYAMLListEncoder(&key.PublicKey)(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(b), "kind: SealedSecret") != 2 {
+		t.Fatalf("expected two sealed secrets but got %q", b)
+	}
+	res, _, err := DecodeSealedSecrets(bytes.NewReader(b), DecodeYAML, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, secrets) {
+		t.Fatalf("expected response to be %+v but got %+v", secrets, res)
+	}
+}
+
+// TestParseKeys tests the ParsePublicKey and ParsePrivateKey functions
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkix, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKeys := map[string][]byte{
+		"pkcs1": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		"pkcs8": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	}
+	for name, b := range privateKeys {
+		res, err := ParsePrivateKey(b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(res.PublicKey, key.PublicKey) {
+			t.Fatalf("%s: expected the parsed key to match", name)
+		}
+	}
+
+	publicKeys := map[string][]byte{
+		"pkix":  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}),
+		"pkcs1": pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}),
+	}
+	for name, b := range publicKeys {
+		res, err := ParsePublicKey(b)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(*res, key.PublicKey) {
+			t.Fatalf("%s: expected the parsed key to match", name)
+		}
+	}
+
+	if _, err := ParsePublicKey([]byte("key")); err == nil || err.Error() != "no PEM data found in public key" {
+		t.Fatalf("expected error to be %q but got %q", "no PEM data found in public key", err)
+	}
+	if _, err := ParsePrivateKey(publicKeys["pkix"]); err == nil || err.Error() != `unexpected PEM block "PUBLIC KEY" in private key` {
+		t.Fatalf("expected error to be %q but got %q", `unexpected PEM block "PUBLIC KEY" in private key`, err)
+	}
+}
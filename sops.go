@@ -0,0 +1,546 @@
+package k8shhh
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	agearmor "filippo.io/age/armor"
+	"golang.org/x/crypto/openpgp"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// sopsKey is the top level key holding the SOPS metadata of a document
+	sopsKey = "sops"
+	// sopsVersion is the SOPS file format version written to the metadata
+	sopsVersion = "3.7.3"
+	// sopsEncryptedRegex restricts the encryption to the values of a secret,
+	// leaving its metadata readable
+	sopsEncryptedRegex = "^(data|stringData)$"
+	// sopsDefaultUnencryptedSuffix is the suffix of the keys left readable
+	// when the metadata sets no other rule, as SOPS does
+	sopsDefaultUnencryptedSuffix = "_unencrypted"
+	// sopsNonceSize is the size of the AES-GCM nonce used by SOPS
+	sopsNonceSize = 32
+)
+
+var (
+	// sopsValueRegexp matches a value encrypted by SOPS
+	sopsValueRegexp = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+	// sopsMetadataRegexp matches the SOPS metadata key of a yaml document
+	sopsMetadataRegexp = regexp.MustCompile(`(?m)^sops:\s*$`)
+)
+
+// SOPSRecipients are the keys the data key of a SOPS encrypted manifest is
+// encrypted to
+type SOPSRecipients struct {
+	// Age are the age X25519 recipients, of the form age1...
+	Age []string
+	// PGPKeyring is the armored or binary OpenPGP keyring holding the public
+	// keys
+	PGPKeyring []byte
+}
+
+// sopsMetadata is the SOPS metadata block of an encrypted document
+type sopsMetadata struct {
+	Age               []sopsAgeKey `yaml:"age,omitempty"`
+	LastModified      string       `yaml:"lastmodified"`
+	MAC               string       `yaml:"mac"`
+	PGP               []sopsPGPKey `yaml:"pgp,omitempty"`
+	UnencryptedSuffix string       `yaml:"unencrypted_suffix,omitempty"`
+	EncryptedSuffix   string       `yaml:"encrypted_suffix,omitempty"`
+	UnencryptedRegex  string       `yaml:"unencrypted_regex,omitempty"`
+	EncryptedRegex    string       `yaml:"encrypted_regex,omitempty"`
+	MACOnlyEncrypted  bool         `yaml:"mac_only_encrypted,omitempty"`
+	Version           string       `yaml:"version"`
+}
+
+// sopsAgeKey is the data key encrypted to an age recipient
+type sopsAgeKey struct {
+	Recipient        string `yaml:"recipient"`
+	EncryptedDataKey string `yaml:"enc"`
+}
+
+// sopsPGPKey is the data key encrypted to an OpenPGP key
+type sopsPGPKey struct {
+	CreatedAt        string `yaml:"created_at"`
+	EncryptedDataKey string `yaml:"enc"`
+	Fingerprint      string `yaml:"fp"`
+}
+
+// sopsLeafFunc is a type for function that is called on each leaf value of a
+// document, with the path of keys leading to it, and returns its new value
+type sopsLeafFunc func(value interface{}, path []string) (interface{}, error)
+
+// SOPSEncoder returns an encoder which outputs the secret to a yaml format
+// encrypted by SOPS to the given recipients, with its values written
+// according to the given mode. Only the values under data and stringData are
+// encrypted, so the metadata of the secret stays readable.
+func SOPSEncoder(mode DataMode, recipients SOPSRecipients) (Encoder, error) {
+	encoder, err := SOPSListEncoder(mode, recipients)
+	if err != nil {
+		return nil, err
+	}
+	return func(secret Secret) ([]byte, error) {
+		return encoder([]Secret{secret})
+	}, nil
+}
+
+// SOPSListEncoder returns a list encoder which outputs the secrets to a yaml
+// format as a stream of documents separated by "---", encrypted by SOPS to
+// the given recipients with a single data key and MAC, as SOPS does
+func SOPSListEncoder(mode DataMode, recipients SOPSRecipients) (ListEncoder, error) {
+	if len(recipients.Age) == 0 && len(recipients.PGPKeyring) == 0 {
+		return nil, errors.New("no SOPS recipient given")
+	}
+	ageEncrypters := make([]Encrypter, 0, len(recipients.Age))
+	for _, r := range recipients.Age {
+		encrypt, err := AgeEncrypter(r)
+		if err != nil {
+			return nil, err
+		}
+		ageEncrypters = append(ageEncrypters, encrypt)
+	}
+	var entities openpgp.EntityList
+	if len(recipients.PGPKeyring) > 0 {
+		var err error
+		if entities, err = readKeyring(recipients.PGPKeyring); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(secrets []Secret) ([]byte, error) {
+		objs, err := generateTemplates(secrets, dataTemplater(mode))
+		if err != nil {
+			return nil, err
+		}
+		docs := make([]yaml.MapSlice, 0, len(objs))
+		for _, obj := range objs {
+			doc, err := toMapSlice(obj)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+
+		dataKey := make([]byte, sessionKeyBytes)
+		if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+			return nil, err
+		}
+		metadata := sopsMetadata{EncryptedRegex: sopsEncryptedRegex, Version: sopsVersion}
+		now := time.Now().UTC()
+		for i, encrypt := range ageEncrypters {
+			enc, err := encrypt(dataKey)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Age = append(metadata.Age, sopsAgeKey{Recipient: recipients.Age[i], EncryptedDataKey: strings.TrimSpace(string(enc)) + "\n"})
+		}
+		for _, entity := range entities {
+			enc, err := pgpEncrypt(openpgp.EntityList{entity}, dataKey)
+			if err != nil {
+				return nil, err
+			}
+			metadata.PGP = append(metadata.PGP, sopsPGPKey{
+				CreatedAt:        now.Format(time.RFC3339),
+				EncryptedDataKey: strings.TrimSpace(string(enc)) + "\n",
+				Fingerprint:      fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+			})
+		}
+
+		hash := sha512.New()
+		for _, doc := range docs {
+			_, err := sopsWalk(doc, nil, func(v interface{}, path []string) (interface{}, error) {
+				b, err := sopsBytes(v)
+				if err != nil {
+					return nil, err
+				}
+				hash.Write(b)
+				if !metadata.encrypts(path) {
+					return v, nil
+				}
+				return sopsEncryptValue(v, dataKey, sopsAdditionalData(path))
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		metadata.LastModified = now.Format(time.RFC3339)
+		mac, err := sopsEncryptValue(fmt.Sprintf("%X", hash.Sum(nil)), dataKey, metadata.LastModified)
+		if err != nil {
+			return nil, err
+		}
+		metadata.MAC = mac.(string)
+
+		var out []byte
+		for i, doc := range docs {
+			b, err := yaml.Marshal(append(doc, yaml.MapItem{Key: sopsKey, Value: metadata}))
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				out = append(out, "---\n"...)
+			}
+			out = append(out, b...)
+		}
+		return out, nil
+	}, nil
+}
+
+// DecryptSOPS decrypts the values of the SOPS encrypted yaml input with the
+// given keys, after checking its MAC, and returns the documents of the input
+// without their SOPS metadata
+func DecryptSOPS(input []byte, keys DecryptionKeys) ([]byte, error) {
+	var docs []yaml.MapSlice
+	var metadata *sopsMetadata
+	d := yaml.NewDecoder(bytes.NewReader(input))
+	for {
+		var doc yaml.MapSlice
+		if err := d.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading SOPS input: %v", err)
+		}
+		for i, item := range doc {
+			if item.Key != sopsKey {
+				continue
+			}
+			if metadata == nil {
+				b, err := yaml.Marshal(item.Value)
+				if err != nil {
+					return nil, err
+				}
+				metadata = &sopsMetadata{}
+				if err := yaml.Unmarshal(b, metadata); err != nil {
+					return nil, fmt.Errorf("reading SOPS metadata: %v", err)
+				}
+			}
+			doc = append(doc[:i:i], doc[i+1:]...)
+			break
+		}
+		docs = append(docs, doc)
+	}
+	if metadata == nil {
+		return nil, errors.New("no SOPS metadata found in input")
+	}
+	if metadata.UnencryptedSuffix == "" && metadata.EncryptedSuffix == "" &&
+		metadata.UnencryptedRegex == "" && metadata.EncryptedRegex == "" {
+		metadata.UnencryptedSuffix = sopsDefaultUnencryptedSuffix
+	}
+
+	dataKey, err := sopsDataKey(*metadata, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha512.New()
+	for _, doc := range docs {
+		_, err := sopsWalk(doc, nil, func(v interface{}, path []string) (interface{}, error) {
+			encrypted := metadata.encrypts(path)
+			if encrypted {
+				var err error
+				if v, err = sopsDecryptValue(v, dataKey, sopsAdditionalData(path)); err != nil {
+					return nil, err
+				}
+			}
+			if encrypted || !metadata.MACOnlyEncrypted {
+				b, err := sopsBytes(v)
+				if err != nil {
+					return nil, err
+				}
+				hash.Write(b)
+			}
+			return v, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lastModified, err := time.Parse(time.RFC3339, metadata.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("reading SOPS metadata: %v", err)
+	}
+	mac, err := sopsDecryptValue(metadata.MAC, dataKey, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting SOPS MAC: %v", err)
+	}
+	if mac != fmt.Sprintf("%X", hash.Sum(nil)) {
+		return nil, errors.New("MAC mismatch, the SOPS input may have been tampered with")
+	}
+
+	var out []byte
+	for i, doc := range docs {
+		b, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			out = append(out, "---\n"...)
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
+
+// isSOPS checks whether the input looks like a SOPS encrypted yaml document
+func isSOPS(input []byte) bool {
+	return sopsMetadataRegexp.Match(input)
+}
+
+// encrypts checks whether the value at the given path is encrypted according
+// to the rules of the metadata, which are applied in the same order as SOPS
+func (m sopsMetadata) encrypts(path []string) bool {
+	encrypted := true
+	if m.UnencryptedSuffix != "" {
+		for _, k := range path {
+			if strings.HasSuffix(k, m.UnencryptedSuffix) {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if m.EncryptedSuffix != "" {
+		encrypted = false
+		for _, k := range path {
+			if strings.HasSuffix(k, m.EncryptedSuffix) {
+				encrypted = true
+				break
+			}
+		}
+	}
+	if m.UnencryptedRegex != "" {
+		for _, k := range path {
+			if matched, _ := regexp.MatchString(m.UnencryptedRegex, k); matched {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if m.EncryptedRegex != "" {
+		encrypted = false
+		for _, k := range path {
+			if matched, _ := regexp.MatchString(m.EncryptedRegex, k); matched {
+				encrypted = true
+				break
+			}
+		}
+	}
+	return encrypted
+}
+
+// sopsDataKey decrypts the data key of the SOPS metadata with the first of
+// the given keys that matches one of its recipients
+func sopsDataKey(metadata sopsMetadata, keys DecryptionKeys) ([]byte, error) {
+	if len(keys.AgeIdentities) == 0 && len(keys.PGPKeyring) == 0 {
+		return nil, errors.New("input is encrypted with SOPS, but no age identity or OpenPGP keyring is given")
+	}
+	var errs []string
+	if len(keys.AgeIdentities) > 0 {
+		for _, k := range metadata.Age {
+			armored := strings.NewReader(strings.TrimSpace(k.EncryptedDataKey))
+			r, err := decryptAge(agearmor.NewReader(armored), keys)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			return ioutil.ReadAll(r)
+		}
+	}
+	if len(keys.PGPKeyring) > 0 {
+		for _, k := range metadata.PGP {
+			r, err := decryptPGP([]byte(strings.TrimSpace(k.EncryptedDataKey)), keys)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			return ioutil.ReadAll(r)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no SOPS recipient matches the given keys")
+	}
+	return nil, fmt.Errorf("decrypting SOPS data key: %s", strings.Join(errs, "; "))
+}
+
+// sopsWalk calls the function on each leaf value of the document in order,
+// replacing the value with its result. As with SOPS, the items of a sequence
+// share the path of the sequence and null values are left out.
+func sopsWalk(value interface{}, path []string, f sopsLeafFunc) (interface{}, error) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		for i, item := range v {
+			res, err := sopsWalk(item.Value, append(path[:len(path):len(path)], fmt.Sprint(item.Key)), f)
+			if err != nil {
+				return nil, err
+			}
+			v[i].Value = res
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			res, err := sopsWalk(item, path, f)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = res
+		}
+		return v, nil
+	case nil:
+		return nil, nil
+	default:
+		return f(v, path)
+	}
+}
+
+// sopsAdditionalData returns the additional data authenticating the value at
+// the given path
+func sopsAdditionalData(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+// sopsBytes returns the bytes of the leaf value, as hashed and encrypted by
+// SOPS
+func sopsBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return []byte(strconv.FormatUint(v, 10)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		if v {
+			return []byte("True"), nil
+		}
+		return []byte("False"), nil
+	default:
+		return nil, fmt.Errorf("unexpected type: %T", value)
+	}
+}
+
+// sopsEncryptValue encrypts the leaf value with the data key and the
+// additional data, to the ENC[AES256_GCM,...] form of SOPS. Empty strings are
+// left as is.
+func sopsEncryptValue(value interface{}, key []byte, additionalData string) (interface{}, error) {
+	var valueType string
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return v, nil
+		}
+		valueType = "str"
+	case int, int64, uint64:
+		valueType = "int"
+	case float64:
+		valueType = "float"
+	case bool:
+		valueType = "bool"
+	}
+	plaintext, err := sopsBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newSOPSAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, sopsNonceSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	out := aead.Seal(nil, iv, plaintext, []byte(additionalData))
+	tag := len(out) - aead.Overhead()
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(out[:tag]),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(out[tag:]),
+		valueType), nil
+}
+
+// sopsDecryptValue decrypts the value encrypted by sopsEncryptValue, and
+// returns it with its original type
+func sopsDecryptValue(value interface{}, key []byte, additionalData string) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("value of %q is not encrypted", additionalData)
+	}
+	if s == "" {
+		return s, nil
+	}
+	m := sopsValueRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("value of %q is not encrypted", additionalData)
+	}
+	var parts [3][]byte
+	for i := range parts {
+		b, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("decrypting value of %q: %v", additionalData, err)
+		}
+		parts[i] = b
+	}
+	aead, err := newSOPSAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts[1]) != sopsNonceSize {
+		return nil, fmt.Errorf("decrypting value of %q: invalid iv size", additionalData)
+	}
+	plaintext, err := aead.Open(nil, parts[1], append(parts[0], parts[2]...), []byte(additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting value of %q: %v", additionalData, err)
+	}
+	switch m[4] {
+	case "str", "bytes":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	default:
+		return nil, fmt.Errorf("decrypting value of %q: unknown type %q", additionalData, m[4])
+	}
+}
+
+// newSOPSAEAD returns the AES-GCM cipher of the given data key, with the
+// nonce size used by SOPS
+func newSOPSAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, sopsNonceSize)
+}
+
+// toMapSlice converts the object to a yaml document which keeps the order of
+// its keys
+func toMapSlice(obj interface{}) (yaml.MapSlice, error) {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
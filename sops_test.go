@@ -0,0 +1,293 @@
+package k8shhh
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/openpgp"
+)
+
+// TestSOPSEncoder tests the SOPSEncoder function along with DecryptInput
+func TestSOPSEncoder(t *testing.T) {
+	t.Parallel()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entity, err := openpgp.NewEntity("k8shhh", "", "k8shhh@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var publicKeyring, privateKeyring bytes.Buffer
+	if err := entity.Serialize(&publicKeyring); err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(&privateKeyring, nil); err != nil {
+		t.Fatal(err)
+	}
+	ageKeys := DecryptionKeys{AgeIdentities: []byte(identity.String())}
+	secret := Secret{Name: "db", Namespace: "production", Labels: map[string]string{"app": "web"}, Data: map[string]string{"DB_HOST": "localhost", "DB_PASSWORD": "", "KEY": "\xff"}}
+
+	tests := []struct {
+		name       string
+		mode       DataMode
+		recipients SOPSRecipients
+		keys       DecryptionKeys
+		tamper     func([]byte) []byte
+		err        error
+	}{
+		{
+			name:       "age",
+			recipients: SOPSRecipients{Age: []string{identity.Recipient().String()}},
+			keys:       ageKeys,
+		},
+		{
+			name:       "age-hybrid",
+			mode:       DataModeHybrid,
+			recipients: SOPSRecipients{Age: []string{other.Recipient().String(), identity.Recipient().String()}},
+			keys:       ageKeys,
+		},
+		{
+			name:       "pgp",
+			recipients: SOPSRecipients{Age: []string{other.Recipient().String()}, PGPKeyring: publicKeyring.Bytes()},
+			keys:       DecryptionKeys{PGPKeyring: privateKeyring.Bytes()},
+		},
+		{
+			name:       "no-key",
+			recipients: SOPSRecipients{Age: []string{identity.Recipient().String()}},
+			err:        errors.New("input is encrypted with SOPS, but no age identity or OpenPGP keyring is given"),
+		},
+		{
+			name:       "wrong-key",
+			recipients: SOPSRecipients{Age: []string{other.Recipient().String()}},
+			keys:       ageKeys,
+			err:        errors.New("decrypting SOPS data key: decrypting age input: no identity matched any of the recipients"),
+		},
+		{
+			name:       "no-matching-recipient",
+			recipients: SOPSRecipients{PGPKeyring: publicKeyring.Bytes()},
+			keys:       ageKeys,
+			err:        errors.New("no SOPS recipient matches the given keys"),
+		},
+		{
+			name:       "tampered-metadata",
+			recipients: SOPSRecipients{Age: []string{identity.Recipient().String()}},
+			keys:       ageKeys,
+			tamper: func(b []byte) []byte {
+				return bytes.Replace(b, []byte("namespace: production"), []byte("namespace: staging"), 1)
+			},
+			err: errors.New("MAC mismatch, the SOPS input may have been tampered with"),
+		},
+		{
+			name:       "moved-value",
+			recipients: SOPSRecipients{Age: []string{identity.Recipient().String()}},
+			keys:       ageKeys,
+			tamper: func(b []byte) []byte {
+				return bytes.Replace(b, []byte("DB_HOST:"), []byte("DB_USER:"), 1)
+			},
+			err: errors.New(`decrypting value of "data:DB_USER:": cipher: message authentication failed`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			encoder, err := SOPSEncoder(test.mode, test.recipients)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := encoder(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range []string{"  name: db\n", "  namespace: production\n", "    app: web\n", "  encrypted_regex: ^(data|stringData)$\n", "  DB_PASSWORD: \"\"\n"} {
+				if !strings.Contains(string(b), s) {
+					t.Fatalf("expected output to contain %q but got %q", s, b)
+				}
+			}
+			if strings.Contains(string(b), "localhost") {
+				t.Fatalf("expected values to be encrypted but got %q", b)
+			}
+			if test.tamper != nil {
+				b = test.tamper(b)
+			}
+
+			r, err := DecryptInput(bytes.NewReader(b), test.keys)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				res, _, err := DecodeSecrets(r, DecodeYAML)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := []Secret{secret}
+				expected[0].Type = SecretTypeOpaque
+				if !reflect.DeepEqual(res, expected) {
+					t.Fatalf("expected response to be %+v but got %+v", expected, res)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestSOPSListEncoder tests the SOPSListEncoder function, whose documents
+// share a single MAC
+func TestSOPSListEncoder(t *testing.T) {
+	t.Parallel()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := []Secret{
+		{Name: "a", Type: SecretTypeOpaque, Data: map[string]string{"a": "b"}},
+		{Name: "b", Type: SecretTypeOpaque, Data: map[string]string{"c": "d"}},
+	}
+	encoder, err := SOPSListEncoder(DataModeStringData, SOPSRecipients{Age: []string{identity.Recipient().String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := encoder(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(b), "\nsops:\n") != 2 {
+		t.Fatalf("expected the SOPS metadata in both documents but got %q", b)
+	}
+
+	keys := DecryptionKeys{AgeIdentities: []byte(identity.String())}
+	r, err := DecryptInput(bytes.NewReader(b), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := DecodeSecrets(r, DecodeYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, secrets) {
+		t.Fatalf("expected response to be %+v but got %+v", secrets, res)
+	}
+
+	// dropping a document changes the MAC
+	first := b[:bytes.Index(b, []byte("\n---\n"))+1]
+	if _, err := DecryptSOPS(first, keys); err == nil || err.Error() != "MAC mismatch, the SOPS input may have been tampered with" {
+		t.Fatalf("expected a MAC mismatch but got %q", err)
+	}
+}
+
+// TestSOPSEncoderError tests the SOPSEncoder function with invalid recipients
+func TestSOPSEncoderError(t *testing.T) {
+	t.Parallel()
+	if _, err := SOPSEncoder(DataModeData, SOPSRecipients{}); err == nil || err.Error() != "no SOPS recipient given" {
+		t.Fatalf("expected error to be %q but got %q", "no SOPS recipient given", err)
+	}
+	if _, err := SOPSEncoder(DataModeData, SOPSRecipients{Age: []string{"age1"}}); err == nil || !strings.HasPrefix(err.Error(), `invalid age recipient "age1"`) {
+		t.Fatalf("expected an invalid recipient error but got %q", err)
+	}
+	if _, err := DecryptSOPS([]byte("a: b\n"), DecryptionKeys{}); err == nil || err.Error() != "no SOPS metadata found in input" {
+		t.Fatalf("expected error to be %q but got %q", "no SOPS metadata found in input", err)
+	}
+}
+
+// TestSOPSEncrypts tests the rules selecting the encrypted values of a SOPS
+// document
+func TestSOPSEncrypts(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		metadata sopsMetadata
+		path     []string
+		res      bool
+	}{
+		{
+			name:     "regex-data",
+			metadata: sopsMetadata{EncryptedRegex: sopsEncryptedRegex},
+			path:     []string{"data", "KEY"},
+			res:      true,
+		},
+		{
+			name:     "regex-metadata",
+			metadata: sopsMetadata{EncryptedRegex: sopsEncryptedRegex},
+			path:     []string{"metadata", "name"},
+			res:      false,
+		},
+		{
+			name:     "regex-nested",
+			metadata: sopsMetadata{EncryptedRegex: sopsEncryptedRegex},
+			path:     []string{"metadata", "labels", "data"},
+			res:      true,
+		},
+		{
+			name:     "unencrypted-suffix",
+			metadata: sopsMetadata{UnencryptedSuffix: sopsDefaultUnencryptedSuffix},
+			path:     []string{"data", "KEY_unencrypted"},
+			res:      false,
+		},
+		{
+			name:     "unencrypted-suffix-other",
+			metadata: sopsMetadata{UnencryptedSuffix: sopsDefaultUnencryptedSuffix},
+			path:     []string{"kind"},
+			res:      true,
+		},
+		{
+			name:     "encrypted-suffix",
+			metadata: sopsMetadata{EncryptedSuffix: "_secret"},
+			path:     []string{"kind"},
+			res:      false,
+		},
+		{
+			name:     "unencrypted-regex",
+			metadata: sopsMetadata{UnencryptedRegex: "^meta"},
+			path:     []string{"metadata", "name"},
+			res:      false,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if res := test.metadata.encrypts(test.path); res != test.res {
+				t.Fatalf("expected response to be %t but got %t", test.res, res)
+			}
+		})
+	}
+}
+
+// TestSOPSValue tests the sopsEncryptValue and sopsDecryptValue functions
+func TestSOPSValue(t *testing.T) {
+	t.Parallel()
+	key := bytes.Repeat([]byte{1}, sessionKeyBytes)
+	format := regexp.MustCompile(`^ENC\[AES256_GCM,data:[A-Za-z0-9+/=]*,iv:[A-Za-z0-9+/=]{44},tag:[A-Za-z0-9+/=]{24},type:(str|int|float|bool)\]$`)
+	for _, v := range []interface{}{"value", 42, 1.5, true, false} {
+		enc, err := sopsEncryptValue(v, key, "data:KEY:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !format.MatchString(enc.(string)) {
+			t.Fatalf("expected a SOPS encrypted value but got %q", enc)
+		}
+		res, err := sopsDecryptValue(enc, key, "data:KEY:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != v {
+			t.Fatalf("expected response to be %v but got %v", v, res)
+		}
+		if _, err := sopsDecryptValue(enc, key, "data:OTHER:"); err == nil {
+			t.Fatalf("expected the value to be bound to its path")
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package k8shhh
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TLSCACertKey is the optional key of a kubernetes.io/tls secret containing
+// the certificate of the issuing CA
+const TLSCACertKey = "ca.crt"
+
+// tlsExpiryWarning is how long before its expiry a certificate is warned
+// about
+const tlsExpiryWarning = 30 * 24 * time.Hour
+
+// TLSData checks the PEM encoded certificate chain and private key, and
+// returns them as the data of a kubernetes.io/tls secret. The private key
+// must match the first certificate, each certificate of the chain must be
+// signed by the next one and, when a CA is given, the chain must verify
+// against it. The validity period of each certificate is checked on its own,
+// and certificates which are expired, not yet valid or about to expire at the
+// given time, including an intermediate or the CA, are reported as warnings
+// rather than errors.
+func TLSData(certPEM, keyPEM, caPEM []byte, now time.Time) (map[string]string, []string, error) {
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, nil, err
+	}
+
+	chain, err := parseCertificates(certPEM)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing certificate: %v", err)
+	}
+	for i := 0; i < len(chain)-1; i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return nil, nil, fmt.Errorf("certificate chain is out of order: %q is not signed by %q", chain[i].Subject, chain[i+1].Subject)
+		}
+	}
+
+	data := map[string]string{
+		TLSCertKey:       string(certPEM),
+		TLSPrivateKeyKey: string(keyPEM),
+	}
+
+	var warnings []string
+	for _, cert := range chain {
+		warnings = append(warnings, checkExpiry(cert, now)...)
+	}
+
+	if len(caPEM) > 0 {
+		cas, err := parseCertificates(caPEM)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing ca certificate: %v", err)
+		}
+		roots := x509.NewCertPool()
+		for _, ca := range cas {
+			roots.AddCert(ca)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			intermediates.AddCert(cert)
+		}
+		verified, err := verifyChain(chain[0], roots, intermediates, append(append([]*x509.Certificate{}, chain...), cas...), now)
+		if err != nil {
+			return nil, nil, fmt.Errorf("verifying certificate against ca: %v", err)
+		}
+		// the validity of the chain has been reported above, so only that of
+		// the ca it is anchored to is left, unless the chain includes it
+		if anchor := verified[len(verified)-1]; !containsCertificate(chain, anchor) {
+			warnings = append(warnings, checkExpiry(anchor, now)...)
+		}
+		data[TLSCACertKey] = string(caPEM)
+	}
+
+	return data, warnings, nil
+}
+
+// verifyChain verifies the leaf certificate against the roots, and returns
+// the verified chain. As the validity periods of the certificates are
+// reported separately, the chain is verified at the first of the given time
+// and the bounds of the validity periods of the given certificates at which it
+// verifies, so that only the errors which hold at any time are returned.
+func verifyChain(leaf *x509.Certificate, roots, intermediates *x509.CertPool, certs []*x509.Certificate, now time.Time) ([]*x509.Certificate, error) {
+	instants := []time.Time{now}
+	for _, cert := range certs {
+		instants = append(instants, cert.NotBefore, cert.NotAfter)
+	}
+	var firstErr error
+	for _, at := range instants {
+		chains, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   at,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err == nil {
+			return chains[0], nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// containsCertificate checks whether the certificates include the given one
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCertificates parses all the PEM encoded certificates of the input
+func parseCertificates(input []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, input = pem.Decode(input)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+// checkExpiry returns the warnings about the validity period of the
+// certificate at the given time
+func checkExpiry(cert *x509.Certificate, now time.Time) []string {
+	switch {
+	case now.After(cert.NotAfter):
+		return []string{fmt.Sprintf("certificate %q expired on %s", cert.Subject, cert.NotAfter.Format(time.RFC3339))}
+	case now.Before(cert.NotBefore):
+		return []string{fmt.Sprintf("certificate %q is not valid before %s", cert.Subject, cert.NotBefore.Format(time.RFC3339))}
+	case now.Add(tlsExpiryWarning).After(cert.NotAfter):
+		return []string{fmt.Sprintf("certificate %q expires on %s", cert.Subject, cert.NotAfter.Format(time.RFC3339))}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package k8shhh
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestTLSData tests the TLSData function
+func TestTLSData(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	year := 365 * 24 * time.Hour
+
+	root, rootKey := generateCertificate(t, "root", now.Add(-year), now.Add(year), nil, nil)
+	inter, interKey := generateCertificate(t, "intermediate", now.Add(-year), now.Add(year), root, rootKey)
+	leaf, leafKey := generateCertificate(t, "leaf", now.Add(-year), now.Add(year), inter, interKey)
+	expiring, expiringKey := generateCertificate(t, "expiring", now.Add(-year), now.Add(24*time.Hour), root, rootKey)
+	expired, expiredKey := generateCertificate(t, "expired", now.Add(-year), now.Add(-24*time.Hour), root, rootKey)
+	other, otherKey := generateCertificate(t, "other", now.Add(-year), now.Add(year), nil, nil)
+	expiredInter, expiredInterKey := generateCertificate(t, "expired-intermediate", now.Add(-2*year), now.Add(-24*time.Hour), root, rootKey)
+	renewed, renewedKey := generateCertificate(t, "renewed", now.Add(-year), now.Add(year), expiredInter, expiredInterKey)
+	expiredRoot, expiredRootKey := generateCertificate(t, "expired-root", now.Add(-2*year), now.Add(-24*time.Hour), nil, nil)
+	orphan, orphanKey := generateCertificate(t, "orphan", now.Add(-year), now.Add(year), expiredRoot, expiredRootKey)
+
+	chain := append(encodeCertificate(leaf), encodeCertificate(inter)...)
+	expiredChain := append(encodeCertificate(renewed), encodeCertificate(expiredInter)...)
+
+	tests := []struct {
+		name     string
+		cert     []byte
+		key      []byte
+		ca       []byte
+		res      map[string]string
+		warnings []string
+		err      error
+	}{
+		{
+			name: "chain",
+			cert: chain,
+			key:  encodeKey(t, leafKey),
+			res:  map[string]string{"tls.crt": string(chain), "tls.key": string(encodeKey(t, leafKey))},
+		},
+		{
+			name: "chain-with-ca",
+			cert: chain,
+			key:  encodeKey(t, leafKey),
+			ca:   encodeCertificate(root),
+			res: map[string]string{
+				"tls.crt": string(chain),
+				"tls.key": string(encodeKey(t, leafKey)),
+				"ca.crt":  string(encodeCertificate(root)),
+			},
+		},
+		{
+			name: "key-mismatch",
+			cert: chain,
+			key:  encodeKey(t, otherKey),
+			err:  errors.New("tls: private key does not match public key"),
+		},
+		{
+			name: "chain-out-of-order",
+			cert: append(encodeCertificate(inter), encodeCertificate(leaf)...),
+			key:  encodeKey(t, interKey),
+			err:  errors.New(`certificate chain is out of order: "CN=intermediate" is not signed by "CN=leaf"`),
+		},
+		{
+			name: "wrong-ca",
+			cert: chain,
+			key:  encodeKey(t, leafKey),
+			ca:   encodeCertificate(other),
+			err:  errors.New(`verifying certificate against ca: x509: certificate signed by unknown authority`),
+		},
+		{
+			name:     "expiring",
+			cert:     encodeCertificate(expiring),
+			key:      encodeKey(t, expiringKey),
+			ca:       encodeCertificate(root),
+			res:      map[string]string{"tls.crt": string(encodeCertificate(expiring)), "tls.key": string(encodeKey(t, expiringKey)), "ca.crt": string(encodeCertificate(root))},
+			warnings: []string{`certificate "CN=expiring" expires on 2020-06-02T00:00:00Z`},
+		},
+		{
+			name:     "expired",
+			cert:     encodeCertificate(expired),
+			key:      encodeKey(t, expiredKey),
+			ca:       encodeCertificate(root),
+			res:      map[string]string{"tls.crt": string(encodeCertificate(expired)), "tls.key": string(encodeKey(t, expiredKey)), "ca.crt": string(encodeCertificate(root))},
+			warnings: []string{`certificate "CN=expired" expired on 2020-05-31T00:00:00Z`},
+		},
+		{
+			name:     "expired-intermediate",
+			cert:     expiredChain,
+			key:      encodeKey(t, renewedKey),
+			ca:       encodeCertificate(root),
+			res:      map[string]string{"tls.crt": string(expiredChain), "tls.key": string(encodeKey(t, renewedKey)), "ca.crt": string(encodeCertificate(root))},
+			warnings: []string{`certificate "CN=expired-intermediate" expired on 2020-05-31T00:00:00Z`},
+		},
+		{
+			name:     "expired-ca",
+			cert:     encodeCertificate(orphan),
+			key:      encodeKey(t, orphanKey),
+			ca:       encodeCertificate(expiredRoot),
+			res:      map[string]string{"tls.crt": string(encodeCertificate(orphan)), "tls.key": string(encodeKey(t, orphanKey)), "ca.crt": string(encodeCertificate(expiredRoot))},
+			warnings: []string{`certificate "CN=expired-root" expired on 2020-05-31T00:00:00Z`},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			res, warnings, err := TLSData(test.cert, test.key, test.ca, now)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+			if !reflect.DeepEqual(res, test.res) {
+				t.Fatalf("expected response to be %q but got %q", test.res, res)
+			}
+			if !reflect.DeepEqual(warnings, test.warnings) {
+				t.Fatalf("expected warnings to be %q but got %q", test.warnings, warnings)
+			}
+		})
+	}
+}
+
+// generateCertificate generates a certificate signed by the given parent, or
+// a self-signed one if the parent is nil
+func generateCertificate(t *testing.T, cn string, notBefore, notAfter time.Time, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// encodeCertificate PEM encodes the given certificate
+func encodeCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+// encodeKey PEM encodes the given private key
+func encodeKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
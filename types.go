@@ -0,0 +1,93 @@
+package k8shhh
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SecretType is the type of a kubernetes secret, which determines the keys
+// that are expected to be present in its data
+type SecretType string
+
+const (
+	// SecretTypeOpaque is the default type for arbitrary user-defined data
+	SecretTypeOpaque SecretType = "Opaque"
+	// SecretTypeServiceAccountToken contains a token identifying a service
+	// account
+	SecretTypeServiceAccountToken SecretType = "kubernetes.io/service-account-token"
+	// SecretTypeDockercfg contains a serialized legacy ~/.dockercfg file
+	SecretTypeDockercfg SecretType = "kubernetes.io/dockercfg"
+	// SecretTypeDockerConfigJSON contains a serialized ~/.docker/config.json
+	// file
+	SecretTypeDockerConfigJSON SecretType = "kubernetes.io/dockerconfigjson"
+	// SecretTypeBasicAuth contains the credentials for basic authentication
+	SecretTypeBasicAuth SecretType = "kubernetes.io/basic-auth"
+	// SecretTypeSSHAuth contains the private key for SSH authentication
+	SecretTypeSSHAuth SecretType = "kubernetes.io/ssh-auth"
+	// SecretTypeTLS contains a certificate and its associated key
+	SecretTypeTLS SecretType = "kubernetes.io/tls"
+)
+
+// The well-known data keys of the typed secrets
+const (
+	DockercfgKey         = ".dockercfg"
+	DockerConfigJSONKey  = ".dockerconfigjson"
+	BasicAuthUsernameKey = "username"
+	BasicAuthPasswordKey = "password"
+	SSHAuthPrivateKey    = "ssh-privatekey"
+	TLSCertKey           = "tls.crt"
+	TLSPrivateKeyKey     = "tls.key"
+)
+
+// ServiceAccountNameKey is the annotation naming the service account of a
+// service account token secret
+const ServiceAccountNameKey = "kubernetes.io/service-account.name"
+
+// validateType checks that the secret contains the keys and annotations
+// required by its type. Secrets of an unknown type are accepted as is, in the
+// same way as the kubernetes API server does.
+func validateType(secret Secret) error {
+	switch secret.Type {
+	case SecretTypeServiceAccountToken:
+		if secret.Annotations[ServiceAccountNameKey] == "" {
+			return fmt.Errorf("secret of type %q requires annotation %q", secret.Type, ServiceAccountNameKey)
+		}
+	case SecretTypeDockercfg:
+		return requireJSON(secret, DockercfgKey)
+	case SecretTypeDockerConfigJSON:
+		return requireJSON(secret, DockerConfigJSONKey)
+	case SecretTypeBasicAuth:
+		_, hasUsername := secret.Data[BasicAuthUsernameKey]
+		_, hasPassword := secret.Data[BasicAuthPasswordKey]
+		if !hasUsername && !hasPassword {
+			return fmt.Errorf("secret of type %q requires key %q or %q", secret.Type, BasicAuthUsernameKey, BasicAuthPasswordKey)
+		}
+	case SecretTypeSSHAuth:
+		return requireKeys(secret, SSHAuthPrivateKey)
+	case SecretTypeTLS:
+		return requireKeys(secret, TLSCertKey, TLSPrivateKeyKey)
+	}
+	return nil
+}
+
+// requireKeys checks that the data of the secret contains all the given keys
+func requireKeys(secret Secret, keys ...string) error {
+	for _, k := range keys {
+		if _, ok := secret.Data[k]; !ok {
+			return fmt.Errorf("secret of type %q requires key %q", secret.Type, k)
+		}
+	}
+	return nil
+}
+
+// requireJSON checks that the data of the secret contains the given key and
+// that its value is valid json
+func requireJSON(secret Secret, key string) error {
+	if err := requireKeys(secret, key); err != nil {
+		return err
+	}
+	if !json.Valid([]byte(secret.Data[key])) {
+		return fmt.Errorf("secret of type %q requires key %q to be valid json", secret.Type, key)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package k8shhh
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestValidateType tests the validateType function
+func TestValidateType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		secret Secret
+		err    error
+	}{
+		{
+			name:   "opaque",
+			secret: Secret{Type: SecretTypeOpaque},
+		},
+		{
+			name:   "unknown-type",
+			secret: Secret{Type: "example.com/custom"},
+		},
+		{
+			name:   "service-account-token",
+			secret: Secret{Type: SecretTypeServiceAccountToken, Annotations: map[string]string{"kubernetes.io/service-account.name": "default"}},
+		},
+		{
+			name:   "service-account-token-missing",
+			secret: Secret{Type: SecretTypeServiceAccountToken},
+			err:    errors.New(`secret of type "kubernetes.io/service-account-token" requires annotation "kubernetes.io/service-account.name"`),
+		},
+		{
+			name:   "basic-auth-username",
+			secret: Secret{Type: SecretTypeBasicAuth, Data: map[string]string{"username": "admin"}},
+		},
+		{
+			name:   "basic-auth-missing",
+			secret: Secret{Type: SecretTypeBasicAuth, Data: map[string]string{"user": "admin"}},
+			err:    errors.New(`secret of type "kubernetes.io/basic-auth" requires key "username" or "password"`),
+		},
+		{
+			name:   "ssh-auth-missing",
+			secret: Secret{Type: SecretTypeSSHAuth},
+			err:    errors.New(`secret of type "kubernetes.io/ssh-auth" requires key "ssh-privatekey"`),
+		},
+		{
+			name:   "tls",
+			secret: Secret{Type: SecretTypeTLS, Data: map[string]string{"tls.crt": "cert", "tls.key": "key"}},
+		},
+		{
+			name:   "tls-missing-key",
+			secret: Secret{Type: SecretTypeTLS, Data: map[string]string{"tls.crt": "cert"}},
+			err:    errors.New(`secret of type "kubernetes.io/tls" requires key "tls.key"`),
+		},
+		{
+			name:   "dockerconfigjson",
+			secret: Secret{Type: SecretTypeDockerConfigJSON, Data: map[string]string{".dockerconfigjson": `{"auths":{}}`}},
+		},
+		{
+			name:   "dockerconfigjson-invalid",
+			secret: Secret{Type: SecretTypeDockerConfigJSON, Data: map[string]string{".dockerconfigjson": `{"auths":`}},
+			err:    errors.New(`secret of type "kubernetes.io/dockerconfigjson" requires key ".dockerconfigjson" to be valid json`),
+		},
+		{
+			name:   "dockercfg-missing",
+			secret: Secret{Type: SecretTypeDockercfg},
+			err:    errors.New(`secret of type "kubernetes.io/dockercfg" requires key ".dockercfg"`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := validateType(test.secret)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
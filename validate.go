@@ -0,0 +1,227 @@
+package k8shhh
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// dns1123LabelMaxLength is the maximum length of a DNS-1123 label
+	dns1123LabelMaxLength = 63
+	// dns1123SubdomainMaxLength is the maximum length of a DNS-1123 subdomain
+	dns1123SubdomainMaxLength = 253
+	// qualifiedNameMaxLength is the maximum length of the name part of a
+	// qualified name
+	qualifiedNameMaxLength = 63
+	// labelValueMaxLength is the maximum length of a label value
+	labelValueMaxLength = 63
+	// annotationsMaxSize is the maximum total size of the annotations
+	annotationsMaxSize = 256 * 1024
+	// secretKeyMaxLength is the maximum length of a secret data key
+	secretKeyMaxLength = 253
+	// secretDataMaxSize is the maximum total size of the data of a secret
+	secretDataMaxSize = 1024 * 1024
+)
+
+var (
+	dns1123LabelRegexp     = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	dns1123SubdomainRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	qualifiedNameRegexp    = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+	labelValueRegexp       = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
+	secretKeyRegexp        = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// ValidationError is a field of a secret which does not follow the
+// kubernetes syntax rules
+type ValidationError struct {
+	// Field names the invalid field, such as "name" or "key"
+	Field string
+	// Value is the invalid value of the field, which is empty for the limits
+	// on the size of a whole field
+	Value string
+	// Reason tells the rule the value breaks
+	Reason string
+}
+
+// Error returns the message of the validation error
+func (e ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// ValidationErrors lists every validation error of a secret
+type ValidationErrors []ValidationError
+
+// Error returns the messages of the validation errors separated by
+// semicolons
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add appends a validation error of the field holding the value, unless the
+// given error is nil
+func (errs *ValidationErrors) add(field, value string, err error) {
+	if err != nil {
+		*errs = append(*errs, ValidationError{Field: field, Value: value, Reason: err.Error()})
+	}
+}
+
+// ValidateSecret checks that the name, the namespace, the labels, the
+// annotations and the data keys of the secret follow the kubernetes syntax
+// rules, and that its data fits in the size limit of a secret. The errors
+// found are returned together as ValidationErrors.
+func ValidateSecret(secret Secret) error {
+	var errs ValidationErrors
+	if secret.Name == "" {
+		errs = append(errs, ValidationError{Field: "name", Reason: "must not be empty"})
+	} else {
+		errs.add("name", secret.Name, validateDNS1123Subdomain(secret.Name))
+	}
+	errs = append(errs, validateMetadata(secret)...)
+	errs = append(errs, validateData(secret.Data)...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateMetadata checks that the namespace, labels and annotations of the
+// secret follow the kubernetes syntax rules
+func validateMetadata(secret Secret) ValidationErrors {
+	var errs ValidationErrors
+	if secret.Namespace != "" {
+		errs.add("namespace", secret.Namespace, validateDNS1123Label(secret.Namespace))
+	}
+	for _, k := range sortedKeys(secret.Labels) {
+		errs.add("label key", k, validateQualifiedName(k))
+		errs.add("label value", secret.Labels[k], validateLabelValue(secret.Labels[k]))
+	}
+	size := 0
+	for _, k := range sortedKeys(secret.Annotations) {
+		errs.add("annotation key", k, validateQualifiedName(strings.ToLower(k)))
+		size += len(k) + len(secret.Annotations[k])
+	}
+	if size > annotationsMaxSize {
+		errs.add("annotations", "", fmt.Errorf("must have at most %d bytes in total", annotationsMaxSize))
+	}
+	return errs
+}
+
+// validateData checks that the keys of the data are valid secret keys, and
+// that the data fits in the size limit of a secret, which only counts the
+// bytes of the values as the API server does
+func validateData(data map[string]string) ValidationErrors {
+	errs := validateDataKeys(data)
+	size := 0
+	for _, v := range data {
+		size += len(v)
+	}
+	if size > secretDataMaxSize {
+		errs.add("data", "", fmt.Errorf("must have at most %d bytes in total", secretDataMaxSize))
+	}
+	return errs
+}
+
+// validateDataKeys checks that the keys of the data are valid secret keys
+func validateDataKeys(data map[string]string) ValidationErrors {
+	var errs ValidationErrors
+	for _, k := range sortedKeys(data) {
+		errs.add("key", k, validateSecretKey(k))
+	}
+	return errs
+}
+
+// validateDNS1123Label checks that the value is a DNS-1123 label, as used
+// for namespaces
+func validateDNS1123Label(value string) error {
+	if len(value) > dns1123LabelMaxLength {
+		return fmt.Errorf("must be no more than %d characters", dns1123LabelMaxLength)
+	}
+	if !dns1123LabelRegexp.MatchString(value) {
+		return fmt.Errorf("must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character")
+	}
+	return nil
+}
+
+// validateDNS1123Subdomain checks that the value is a DNS-1123 subdomain, as
+// used for object names and key prefixes
+func validateDNS1123Subdomain(value string) error {
+	if len(value) > dns1123SubdomainMaxLength {
+		return fmt.Errorf("must be no more than %d characters", dns1123SubdomainMaxLength)
+	}
+	if !dns1123SubdomainRegexp.MatchString(value) {
+		return fmt.Errorf("must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character")
+	}
+	return nil
+}
+
+// validateQualifiedName checks that the value is a qualified name with an
+// optional DNS-1123 subdomain prefix, as used for label and annotation keys
+func validateQualifiedName(value string) error {
+	name := value
+	if i := strings.Index(value, "/"); i >= 0 {
+		prefix := value[:i]
+		name = value[i+1:]
+		if prefix == "" {
+			return fmt.Errorf("prefix part must be non-empty")
+		}
+		if err := validateDNS1123Subdomain(prefix); err != nil {
+			return fmt.Errorf("prefix part %v", err)
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("name part must be non-empty")
+	}
+	if len(name) > qualifiedNameMaxLength {
+		return fmt.Errorf("name part must be no more than %d characters", qualifiedNameMaxLength)
+	}
+	if !qualifiedNameRegexp.MatchString(name) {
+		return fmt.Errorf("name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character")
+	}
+	return nil
+}
+
+// validateLabelValue checks that the value is a valid label value
+func validateLabelValue(value string) error {
+	if len(value) > labelValueMaxLength {
+		return fmt.Errorf("must be no more than %d characters", labelValueMaxLength)
+	}
+	if !labelValueRegexp.MatchString(value) {
+		return fmt.Errorf("must be empty or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character")
+	}
+	return nil
+}
+
+// validateSecretKey checks that the value is a valid key for the data of a
+// secret
+func validateSecretKey(value string) error {
+	if len(value) > secretKeyMaxLength {
+		return fmt.Errorf("must be no more than %d characters", secretKeyMaxLength)
+	}
+	if !secretKeyRegexp.MatchString(value) {
+		return fmt.Errorf("must consist of alphanumeric characters, '-', '_' or '.'")
+	}
+	if value == "." || value == ".." || strings.HasPrefix(value, "..") {
+		return fmt.Errorf("must not be '.' or '..', or start with '..'")
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of the given map in sorted order, so that
+// validation errors are reported deterministically
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
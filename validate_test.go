@@ -0,0 +1,150 @@
+package k8shhh
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestValidateMetadata tests the validateMetadata function
+func TestValidateMetadata(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		secret Secret
+		err    error
+	}{
+		{
+			name:   "empty",
+			secret: Secret{},
+		},
+		{
+			name: "valid",
+			secret: Secret{
+				Namespace:   "kube-system",
+				Labels:      map[string]string{"app.kubernetes.io/name": "etcd", "tier": ""},
+				Annotations: map[string]string{"example.com/Owner": "Team A"},
+			},
+		},
+		{
+			name:   "namespace-uppercase",
+			secret: Secret{Namespace: "Default"},
+			err:    errors.New(`invalid namespace "Default": must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character`),
+		},
+		{
+			name:   "label-key-empty-prefix",
+			secret: Secret{Labels: map[string]string{"/name": "a"}},
+			err:    errors.New(`invalid label key "/name": prefix part must be non-empty`),
+		},
+		{
+			name:   "label-key-bad-prefix",
+			secret: Secret{Labels: map[string]string{"Example.com/name": "a"}},
+			err:    errors.New(`invalid label key "Example.com/name": prefix part must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character`),
+		},
+		{
+			name:   "label-key-too-long",
+			secret: Secret{Labels: map[string]string{strings.Repeat("a", 64): "a"}},
+			err:    errors.New(`invalid label key "` + strings.Repeat("a", 64) + `": name part must be no more than 63 characters`),
+		},
+		{
+			name:   "label-value-invalid",
+			secret: Secret{Labels: map[string]string{"app": "-etcd"}},
+			err:    errors.New(`invalid label value "-etcd": must be empty or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character`),
+		},
+		{
+			name:   "annotation-key-invalid",
+			secret: Secret{Annotations: map[string]string{"a b": "c"}},
+			err:    errors.New(`invalid annotation key "a b": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character`),
+		},
+		{
+			name:   "annotations-too-large",
+			secret: Secret{Annotations: map[string]string{"a": strings.Repeat("a", 256*1024)}},
+			err:    errors.New(`annotations must have at most 262144 bytes in total`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := validateMetadata(test.secret)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			} else {
+				if test.err == nil || err.Error() != test.err.Error() {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+			}
+		})
+	}
+}
+
+// TestValidateSecret tests the ValidateSecret function
+func TestValidateSecret(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		secret Secret
+		err    error
+	}{
+		{
+			name:   "valid",
+			secret: Secret{Name: "db.example-1", Namespace: "default", Data: map[string]string{"tls.crt": "a", "DB_HOST": "b", "-a_b.c": ""}},
+		},
+		{
+			name:   "name-empty",
+			secret: Secret{},
+			err:    errors.New(`name must not be empty`),
+		},
+		{
+			name:   "name-invalid",
+			secret: Secret{Name: "My_Secret"},
+			err:    errors.New(`invalid name "My_Secret": must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character`),
+		},
+		{
+			name:   "name-too-long",
+			secret: Secret{Name: strings.Repeat("a", 254)},
+			err:    errors.New(`invalid name "` + strings.Repeat("a", 254) + `": must be no more than 253 characters`),
+		},
+		{
+			name:   "keys-invalid",
+			secret: Secret{Name: "db", Data: map[string]string{"a b": "c", "..a": "c", "ok": "c"}},
+			err:    errors.New(`invalid key "..a": must not be '.' or '..', or start with '..'; invalid key "a b": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+		{
+			name:   "data-at-limit",
+			secret: Secret{Name: "db", Data: map[string]string{"a": strings.Repeat("a", 1024*1024-1), "long-key": "b"}},
+		},
+		{
+			name:   "data-too-large",
+			secret: Secret{Name: "db", Data: map[string]string{"a": strings.Repeat("a", 1024*1024), "b": "c"}},
+			err:    errors.New(`data must have at most 1048576 bytes in total`),
+		},
+		{
+			name:   "several",
+			secret: Secret{Name: "-db", Namespace: "Default", Labels: map[string]string{"app": "-db"}, Data: map[string]string{"a@b": "c"}},
+			err:    errors.New(`invalid name "-db": must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character; invalid namespace "Default": must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character; invalid label value "-db": must be empty or consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character; invalid key "a@b": must consist of alphanumeric characters, '-', '_' or '.'`),
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateSecret(test.secret)
+			if err == nil {
+				if test.err != nil {
+					t.Fatalf("expected error to be %q but got %q", test.err, err)
+				}
+				return
+			}
+			if test.err == nil || err.Error() != test.err.Error() {
+				t.Fatalf("expected error to be %q but got %q", test.err, err)
+			}
+			var errs ValidationErrors
+			if !errors.As(err, &errs) || len(errs) == 0 {
+				t.Fatalf("expected error to be ValidationErrors but got %T", err)
+			}
+		})
+	}
+}